@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/shortly/internal/cache"
+	"github.com/shortly/internal/config"
+	"github.com/shortly/internal/database"
+	"github.com/shortly/internal/importers"
+	"github.com/shortly/internal/services"
+)
+
+// runImport implements `shortly import`, loading another shortener's export
+// straight into the database. It prints the import report as JSON.
+func runImport(args []string) int {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	source := fs.String("source", "", "export format: bitly, yourls or kutt")
+	userID := fs.Int("user", 0, "id of the user who will own the links")
+	seedClicks := fs.Bool("seed-clicks", false, "carry over click totals from the export")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "usage: shortly import -source bitly|yourls|kutt -user ID [-seed-clicks] FILE")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *source == "" || *userID == 0 || fs.NArg() != 1 {
+		fs.Usage()
+		return 2
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer f.Close()
+
+	records, err := importers.Parse(*source, f)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	cfg := config.Load()
+	db, err := database.Connect(cfg.DatabaseURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "db:", err)
+		return 1
+	}
+	defer db.Close()
+	if err := database.RunMigrations(db); err != nil {
+		fmt.Fprintln(os.Stderr, "migrations:", err)
+		return 1
+	}
+
+	// created links are written to the shared redirect cache and announced to
+	// running servers. without redis, those servers' entries for the codes
+	// can only be "missing", which expire within 30 seconds
+	rdb, err := cache.NewRedisCache(cfg.RedisURL)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "warning: redis unavailable, importing without the shared cache:", err)
+		rdb = nil
+	} else {
+		defer rdb.Close()
+	}
+
+	linkSvc := services.NewLinkService(db, cache.NewTiered(cache.NewMemory(cfg.CacheSize), rdb, cfg.CacheLocalTTL()), cfg)
+	report, err := linkSvc.ImportExternal(context.Background(), *userID, *source, records, *seedClicks)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "import:", err)
+		return 1
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	enc.Encode(report)
+	if len(report.Errors) > 0 || len(report.Conflicts) > 0 {
+		return 1
+	}
+	return 0
+}
@@ -1,10 +1,15 @@
 package main
 
 import (
-	"fmt"
+	"context"
+	"errors"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
+	_ "time/tzdata" // stats tz names must resolve in the alpine image too
 
 	"github.com/go-chi/chi/v5"
 	chimw "github.com/go-chi/chi/v5/middleware"
@@ -20,6 +25,10 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		os.Exit(runImport(os.Args[2:]))
+	}
+
 	cfg := config.Load()
 
 	// database
@@ -37,22 +46,43 @@ func main() {
 	// redis
 	rdb, err := cache.NewRedisCache(cfg.RedisURL)
 	if err != nil {
-		log.Println("warning: redis unavailable, running without cache:", err)
+		log.Println("warning: redis unavailable, running with the in-memory cache only:", err)
 		rdb = nil
 	} else {
 		defer rdb.Close()
 		log.Println("redis connected")
 	}
+	linkCache := cache.NewTiered(cache.NewMemory(cfg.CacheSize), rdb, cfg.CacheLocalTTL())
 
 	// services
 	authSvc := services.NewAuthService(db, cfg.JWTSecret)
-	linkSvc := services.NewLinkService(db, rdb, cfg)
-	clickSvc := services.NewClickService(db)
+	linkSvc := services.NewLinkService(db, linkCache, cfg)
+	clickSvc := services.NewClickService(db, services.NewGeoService(), cfg)
+	tagSvc := services.NewTagService(db)
+	guard := services.NewUnlockGuard(db, rdb)
+
+	// background jobs
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go linkSvc.RunTrashPurger(ctx, time.Hour)
+	go linkCache.Listen(ctx)
+	clickSvc.Start()
+	go clickSvc.RunSpoolReplayer(ctx, 10*time.Second)
+	go clickSvc.RunRollups(ctx, time.Minute)
+
+	if codes, err := linkSvc.ShadowedCodes(ctx); err != nil {
+		log.Println("reserved code check:", err)
+	} else if len(codes) > 0 {
+		log.Printf("warning: links %v can't be opened, a route of the same name comes first; recreate them under other codes", codes)
+	}
 
 	// handlers
 	authH := handlers.NewAuthHandler(authSvc)
-	linkH := handlers.NewLinkHandler(linkSvc, clickSvc)
+	linkH := handlers.NewLinkHandler(linkSvc, clickSvc, guard)
+	tagH := handlers.NewTagHandler(tagSvc)
 	qrH := handlers.NewQRHandler(cfg)
+	statsH := handlers.NewStatsHandler(clickSvc)
+	metricsH := handlers.NewMetricsHandler(clickSvc)
 
 	// router
 	r := chi.NewRouter()
@@ -62,7 +92,7 @@ func main() {
 	r.Use(chimw.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		AllowedOrigins:   []string{"http://localhost:*", "https://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Authorization", "Content-Type"},
 		AllowCredentials: true,
 	}))
@@ -73,6 +103,8 @@ func main() {
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 	r.Get("/{code}", linkH.Redirect)
+	r.Head("/{code}", linkH.Redirect) // link checkers; recorded as bot clicks
+	r.With(httprate.LimitByIP(30, time.Minute)).Post("/{code}/unlock", linkH.Unlock)
 	r.Get("/qr/{code}", qrH.Generate)
 
 	// auth
@@ -87,13 +119,72 @@ func main() {
 		r.Use(middleware.JWTAuth(cfg.JWTSecret))
 
 		r.Post("/links", linkH.Create)
+		r.Post("/links/bulk", linkH.BulkCreate)
+		r.Post("/links/batch", linkH.Batch)
+		r.Get("/links/export", linkH.Export)
+		r.Post("/links/import", linkH.Import)
+		r.Post("/links/import/{source}", linkH.ImportExternal)
 		r.Get("/links", linkH.List)
+		r.Get("/links/trash", linkH.ListTrash)
+		r.Patch("/links/{id}", linkH.Update)
 		r.Delete("/links/{id}", linkH.Delete)
+		r.Post("/links/{id}/restore", linkH.Restore)
 		r.Get("/links/{id}/stats", linkH.GetStats)
+		r.Get("/links/{id}/clicks", linkH.ListClicks)
+		r.Get("/links/{id}/history", linkH.History)
+		r.Get("/links/{id}/security-events", linkH.SecurityEvents)
+		r.Post("/links/{id}/history/{rev}/restore", linkH.RestoreRevision)
+		r.Post("/links/{id}/tags", linkH.AddTags)
+		r.Delete("/links/{id}/tags/{tagID}", linkH.RemoveTag)
+
+		r.Get("/tags", tagH.List)
+		r.Post("/tags", tagH.Create)
+		r.Patch("/tags/{id}", tagH.Rename)
+		r.Delete("/tags/{id}", tagH.Delete)
+		r.Post("/tags/{id}/merge", tagH.Merge)
+		r.Get("/tags/{id}/stats", statsH.Tag)
+
+		r.Get("/stats/overview", statsH.Overview)
 	})
 
-	log.Printf("shortly running on :%s", cfg.Port)
-	if err := http.ListenAndServe(":"+cfg.Port, r); err != nil {
-		log.Fatal(err)
+	srv := &http.Server{Addr: ":" + cfg.Port, Handler: r}
+	go func() {
+		log.Printf("shortly running on :%s", cfg.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatal(err)
+		}
+	}()
+
+	// metrics get their own listener, off the public host and out of the
+	// short code namespace
+	var metricsSrv *http.Server
+	if cfg.MetricsAddr != "" {
+		m := chi.NewRouter()
+		m.Get("/metrics", metricsH.Serve)
+		metricsSrv = &http.Server{Addr: cfg.MetricsAddr, Handler: m}
+		go func() {
+			log.Printf("metrics on %s", cfg.MetricsAddr)
+			if err := metricsSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				log.Println("metrics:", err)
+			}
+		}()
+	}
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+	log.Println("shutting down")
+
+	// stop taking requests first so no click is queued after the flush
+	shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), 15*time.Second)
+	defer cancelShutdown()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Println("http shutdown:", err)
+	}
+	if metricsSrv != nil {
+		metricsSrv.Shutdown(shutdownCtx)
+	}
+	if err := clickSvc.Close(shutdownCtx); err != nil {
+		log.Println(err)
 	}
 }
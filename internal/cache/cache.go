@@ -0,0 +1,36 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrMiss is returned by Get when the key isn't cached.
+var ErrMiss = errors.New("cache miss")
+
+// Cache is the JSON key/value store the services use. Implementations must
+// be safe for concurrent use.
+type Cache interface {
+	Get(ctx context.Context, key string, dest interface{}) error
+	Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	Delete(ctx context.Context, key string) error
+	DeleteMany(ctx context.Context, keys ...string) error
+}
+
+// SetQuiet writes through c's SetQuiet when it has one (see
+// Tiered.SetQuiet) and through Set otherwise.
+func SetQuiet(ctx context.Context, c Cache, key string, value interface{}, ttl time.Duration) error {
+	if q, ok := c.(interface {
+		SetQuiet(ctx context.Context, key string, value interface{}, ttl time.Duration) error
+	}); ok {
+		return q.SetQuiet(ctx, key, value, ttl)
+	}
+	return c.Set(ctx, key, value, ttl)
+}
+
+var (
+	_ Cache = (*Memory)(nil)
+	_ Cache = (*RedisCache)(nil)
+	_ Cache = (*Tiered)(nil)
+)
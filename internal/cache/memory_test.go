@@ -0,0 +1,122 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMemoryGetSet(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(10)
+
+	type entry struct {
+		URL string `json:"url"`
+	}
+	if err := m.Set(ctx, "a", entry{URL: "https://example.com"}, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	var got entry
+	if err := m.Get(ctx, "a", &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.URL != "https://example.com" {
+		t.Errorf("Get = %+v", got)
+	}
+	if err := m.Get(ctx, "b", &got); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get(missing) err = %v, want ErrMiss", err)
+	}
+}
+
+func TestMemoryTTL(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(10)
+	now := time.Now()
+	m.now = func() time.Time { return now }
+
+	m.Set(ctx, "a", 1, time.Minute)
+	m.Set(ctx, "zero", 1, 0)
+
+	var v int
+	if err := m.Get(ctx, "a", &v); err != nil {
+		t.Fatalf("fresh entry: %v", err)
+	}
+	if err := m.Get(ctx, "zero", &v); !errors.Is(err, ErrMiss) {
+		t.Errorf("zero ttl entry was stored")
+	}
+
+	now = now.Add(time.Minute)
+	if err := m.Get(ctx, "a", &v); !errors.Is(err, ErrMiss) {
+		t.Errorf("expired entry still served")
+	}
+	if m.Len() != 0 {
+		t.Errorf("Len = %d after expiry, want 0", m.Len())
+	}
+}
+
+func TestMemoryEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(2)
+	var v int
+
+	m.Set(ctx, "a", 1, time.Minute)
+	m.Set(ctx, "b", 2, time.Minute)
+	m.Get(ctx, "a", &v) // a is now newer than b
+	m.Set(ctx, "c", 3, time.Minute)
+
+	if err := m.Get(ctx, "b", &v); !errors.Is(err, ErrMiss) {
+		t.Errorf("b should have been evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if err := m.Get(ctx, key, &v); err != nil {
+			t.Errorf("%s evicted: %v", key, err)
+		}
+	}
+
+	// overwriting doesn't grow the cache
+	m.Set(ctx, "c", 4, time.Minute)
+	if m.Len() != 2 {
+		t.Errorf("Len = %d, want 2", m.Len())
+	}
+	if m.Get(ctx, "c", &v); v != 4 {
+		t.Errorf("c = %d, want 4", v)
+	}
+}
+
+func TestMemoryDeleteMany(t *testing.T) {
+	ctx := context.Background()
+	m := NewMemory(10)
+	for _, key := range []string{"a", "b", "c"} {
+		m.Set(ctx, key, key, time.Minute)
+	}
+	m.DeleteMany(ctx, "a", "c", "missing")
+
+	var v string
+	if err := m.Get(ctx, "b", &v); err != nil {
+		t.Errorf("b deleted: %v", err)
+	}
+	if m.Len() != 1 {
+		t.Errorf("Len = %d, want 1", m.Len())
+	}
+}
+
+func TestTieredWithoutRemote(t *testing.T) {
+	ctx := context.Background()
+	c := NewTiered(NewMemory(10), nil, time.Minute)
+
+	if err := c.Set(ctx, "a", "x", time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	var v string
+	if err := c.Get(ctx, "a", &v); err != nil || v != "x" {
+		t.Fatalf("Get = %q, %v", v, err)
+	}
+	if err := c.Delete(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, "a", &v); !errors.Is(err, ErrMiss) {
+		t.Errorf("Get after Delete err = %v, want ErrMiss", err)
+	}
+	c.Listen(ctx) // returns straight away without redis
+}
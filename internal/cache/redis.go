@@ -3,6 +3,7 @@ package cache
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"time"
 
@@ -32,11 +33,19 @@ func NewRedisCache(redisURL string) (*RedisCache, error) {
 }
 
 func (c *RedisCache) Get(ctx context.Context, key string, dest interface{}) error {
-	val, err := c.client.Get(ctx, key).Result()
+	val, err := c.getRaw(ctx, key)
 	if err != nil {
 		return err
 	}
-	return json.Unmarshal([]byte(val), dest)
+	return json.Unmarshal(val, dest)
+}
+
+func (c *RedisCache) getRaw(ctx context.Context, key string) ([]byte, error) {
+	val, err := c.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrMiss
+	}
+	return val, err
 }
 
 func (c *RedisCache) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
@@ -51,10 +60,60 @@ func (c *RedisCache) Delete(ctx context.Context, key string) error {
 	return c.client.Del(ctx, key).Err()
 }
 
+// DeleteMany removes several keys in a single pipeline round trip.
+func (c *RedisCache) DeleteMany(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	pipe := c.client.Pipeline()
+	for _, key := range keys {
+		pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
 func (c *RedisCache) Increment(ctx context.Context, key string) (int64, error) {
 	return c.client.Incr(ctx, key).Result()
 }
 
+// IncrementTTL increments key and starts its expiry if it has none yet, so
+// the counter resets ttl after the first increment.
+func (c *RedisCache) IncrementTTL(ctx context.Context, key string, ttl time.Duration) (int64, error) {
+	pipe := c.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.ExpireNX(ctx, key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return incr.Val(), nil
+}
+
+// TTL returns the remaining lifetime of key, or a negative duration if the
+// key doesn't exist or never expires.
+func (c *RedisCache) TTL(ctx context.Context, key string) (time.Duration, error) {
+	return c.client.TTL(ctx, key).Result()
+}
+
+// Subscribe calls fn with every message published on channel until ctx is
+// done. The client resubscribes by itself after connection drops.
+func (c *RedisCache) Subscribe(ctx context.Context, channel string, fn func(payload string)) {
+	sub := c.client.Subscribe(ctx, channel)
+	defer sub.Close()
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-ch:
+			if !ok {
+				return
+			}
+			fn(msg.Payload)
+		}
+	}
+}
+
 func (c *RedisCache) Close() error {
 	return c.client.Close()
 }
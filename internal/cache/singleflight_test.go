@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupCollapsesConcurrentCalls(t *testing.T) {
+	var g Group[int]
+	var runs atomic.Int32
+	release := make(chan struct{})
+	fn := func() (int, error) {
+		runs.Add(1)
+		<-release
+		return 42, nil
+	}
+
+	var wg sync.WaitGroup
+	results := make([]int, 10)
+	shared := make([]bool, 10)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], _, shared[i] = g.Do("k", fn)
+		}(i)
+	}
+	// let every caller reach Do before the first one returns
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := runs.Load(); n != 1 {
+		t.Errorf("fn ran %d times, want 1", n)
+	}
+	owners := 0
+	for i, v := range results {
+		if v != 42 {
+			t.Errorf("caller %d got %d", i, v)
+		}
+		if !shared[i] {
+			owners++
+		}
+	}
+	if owners != 1 {
+		t.Errorf("%d callers not shared, want 1", owners)
+	}
+}
+
+func TestGroupSequentialCallsRunAgain(t *testing.T) {
+	var g Group[string]
+	errBoom := errors.New("boom")
+
+	_, err, shared := g.Do("k", func() (string, error) { return "", errBoom })
+	if !errors.Is(err, errBoom) || shared {
+		t.Fatalf("Do = %v, shared %v", err, shared)
+	}
+	v, err, shared := g.Do("k", func() (string, error) { return "ok", nil })
+	if err != nil || v != "ok" || shared {
+		t.Fatalf("second Do = %q, %v, shared %v", v, err, shared)
+	}
+}
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"time"
+)
+
+const invalidationChannel = "shortly:cache:invalidate"
+
+// Tiered puts an in-process Memory cache in front of an optional Redis.
+// Reads try memory first and fall back to Redis; writes and deletes go to
+// both and are announced over Redis pub/sub so every other instance drops
+// its local copy. Local entries also expire after localTTL, which bounds
+// staleness if an invalidation is ever lost. With no Redis it is just the
+// memory tier.
+type Tiered struct {
+	local    *Memory
+	remote   *RedisCache
+	localTTL time.Duration
+	origin   string
+}
+
+type invalidation struct {
+	Origin string   `json:"origin"`
+	Keys   []string `json:"keys"`
+}
+
+// NewTiered builds a two-tier cache; remote may be nil.
+func NewTiered(local *Memory, remote *RedisCache, localTTL time.Duration) *Tiered {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return &Tiered{local: local, remote: remote, localTTL: localTTL, origin: hex.EncodeToString(b)}
+}
+
+func (t *Tiered) Get(ctx context.Context, key string, dest interface{}) error {
+	if data, ok := t.local.getRaw(key); ok {
+		return json.Unmarshal(data, dest)
+	}
+	if t.remote == nil {
+		return ErrMiss
+	}
+	data, err := t.remote.getRaw(ctx, key)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return err
+	}
+	t.local.setRaw(key, data, t.localTTL)
+	return nil
+}
+
+func (t *Tiered) Set(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	t.local.setRaw(key, data, min(ttl, t.localTTL))
+	if t.remote == nil {
+		return nil
+	}
+	if err := t.remote.client.Set(ctx, key, data, ttl).Err(); err != nil {
+		return err
+	}
+	return t.publish(ctx, key)
+}
+
+// SetQuiet writes like Set but doesn't announce the write, and leaves an
+// existing Redis entry alone. It is for negative entries: no other instance
+// can hold a copy that needs dropping, and a link stored under the key in
+// the meantime must not be overwritten.
+func (t *Tiered) SetQuiet(ctx context.Context, key string, value interface{}, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	t.local.setRaw(key, data, min(ttl, t.localTTL))
+	if t.remote == nil {
+		return nil
+	}
+	return t.remote.client.SetNX(ctx, key, data, ttl).Err()
+}
+
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	return t.DeleteMany(ctx, key)
+}
+
+func (t *Tiered) DeleteMany(ctx context.Context, keys ...string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	t.local.DeleteMany(ctx, keys...)
+	if t.remote == nil {
+		return nil
+	}
+	if err := t.remote.DeleteMany(ctx, keys...); err != nil {
+		return err
+	}
+	return t.publish(ctx, keys...)
+}
+
+// Listen drops local entries invalidated by other instances until ctx is
+// done. It returns immediately without Redis.
+func (t *Tiered) Listen(ctx context.Context) {
+	if t.remote == nil {
+		return
+	}
+	t.remote.Subscribe(ctx, invalidationChannel, func(payload string) {
+		var msg invalidation
+		if err := json.Unmarshal([]byte(payload), &msg); err != nil {
+			log.Println("cache invalidation:", err)
+			return
+		}
+		if msg.Origin != t.origin {
+			t.local.DeleteMany(ctx, msg.Keys...)
+		}
+	})
+}
+
+func (t *Tiered) publish(ctx context.Context, keys ...string) error {
+	data, err := json.Marshal(invalidation{Origin: t.origin, Keys: keys})
+	if err != nil {
+		return err
+	}
+	return t.remote.client.Publish(ctx, invalidationChannel, data).Err()
+}
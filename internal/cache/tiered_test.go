@@ -0,0 +1,23 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSetQuiet(t *testing.T) {
+	ctx := context.Background()
+	for name, c := range map[string]Cache{
+		"memory": NewMemory(10),
+		"tiered": NewTiered(NewMemory(10), nil, time.Minute),
+	} {
+		if err := SetQuiet(ctx, c, "a", 1, time.Minute); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		var got int
+		if err := c.Get(ctx, "a", &got); err != nil || got != 1 {
+			t.Errorf("%s: Get = %d, %v", name, got, err)
+		}
+	}
+}
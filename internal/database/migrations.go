@@ -61,6 +61,92 @@ func RunMigrations(pool *pgxpool.Pool) error {
 			tag_id INTEGER REFERENCES tags(id) ON DELETE CASCADE,
 			PRIMARY KEY (link_id, tag_id)
 		)`,
+		`ALTER TABLE links ADD COLUMN IF NOT EXISTS revision INTEGER NOT NULL DEFAULT 1`,
+		`CREATE TABLE IF NOT EXISTS link_revisions (
+			id SERIAL PRIMARY KEY,
+			link_id INTEGER REFERENCES links(id) ON DELETE CASCADE NOT NULL,
+			revision INTEGER NOT NULL,
+			original_url TEXT NOT NULL,
+			title VARCHAR(200),
+			is_active BOOLEAN,
+			expires_at TIMESTAMPTZ,
+			max_clicks INTEGER,
+			has_password BOOLEAN DEFAULT false,
+			changed_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+			created_at TIMESTAMPTZ DEFAULT NOW(),
+			UNIQUE(link_id, revision)
+		)`,
+		// links created before revisions existed get their current state as revision 1
+		`INSERT INTO link_revisions (link_id, revision, original_url, title, is_active, expires_at, max_clicks, has_password, changed_by, created_at)
+		 SELECT id, revision, original_url, title, is_active, expires_at, max_clicks, COALESCE(password_hash, '') <> '', user_id, created_at
+		 FROM links ON CONFLICT (link_id, revision) DO NOTHING`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS revision INTEGER`,
+		// link search: trigram indexes back ILIKE, the expression index backs the
+		// destination domain filter (must match linkDomainExpr in services)
+		`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+		`CREATE INDEX IF NOT EXISTS idx_links_title_trgm ON links USING gin (title gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_links_original_url_trgm ON links USING gin (original_url gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_links_short_code_trgm ON links USING gin (short_code gin_trgm_ops)`,
+		`CREATE INDEX IF NOT EXISTS idx_links_domain ON links (lower(substring(original_url from '^[a-zA-Z]+://([^/:?#]+)')))`,
+		`CREATE INDEX IF NOT EXISTS idx_links_user_created ON links(user_id, created_at DESC, id DESC)`,
+		`CREATE INDEX IF NOT EXISTS idx_clicks_link_created ON clicks(link_id, created_at DESC, id DESC)`,
+		`ALTER TABLE links ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMPTZ`,
+		`CREATE INDEX IF NOT EXISTS idx_links_deleted_at ON links(deleted_at) WHERE deleted_at IS NOT NULL`,
+		// click totals carried over from another shortener on import
+		`ALTER TABLE links ADD COLUMN IF NOT EXISTS imported_clicks INTEGER NOT NULL DEFAULT 0`,
+		`CREATE TABLE IF NOT EXISTS link_security_events (
+			id SERIAL PRIMARY KEY,
+			link_id INTEGER REFERENCES links(id) ON DELETE CASCADE NOT NULL,
+			event_type VARCHAR(30) NOT NULL,
+			ip_address VARCHAR(45),
+			user_agent TEXT,
+			created_at TIMESTAMPTZ DEFAULT NOW()
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_link_security_events_link ON link_security_events(link_id, created_at DESC)`,
+		// burn-after-reading links; consumed_at is set once by the first visit
+		`ALTER TABLE links ADD COLUMN IF NOT EXISTS single_use BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE links ADD COLUMN IF NOT EXISTS consumed_at TIMESTAMPTZ`,
+		// denormalized visit counter that max_clicks is enforced against;
+		// added nullable so existing links can be backfilled from clicks
+		`ALTER TABLE links ADD COLUMN IF NOT EXISTS click_count INTEGER`,
+		`UPDATE links SET click_count = (SELECT COUNT(*) FROM clicks WHERE link_id = links.id) WHERE click_count IS NULL`,
+		`ALTER TABLE links ALTER COLUMN click_count SET DEFAULT 0`,
+		`ALTER TABLE links ALTER COLUMN click_count SET NOT NULL`,
+		// pre-aggregated clicks per link, dimension and UTC hour/day;
+		// dimension 'total' has an empty value
+		`CREATE TABLE IF NOT EXISTS click_rollups_hourly (
+			link_id INTEGER REFERENCES links(id) ON DELETE CASCADE NOT NULL,
+			dimension VARCHAR(20) NOT NULL,
+			bucket TIMESTAMPTZ NOT NULL,
+			value TEXT NOT NULL,
+			clicks INTEGER NOT NULL,
+			PRIMARY KEY (link_id, dimension, bucket, value)
+		)`,
+		`CREATE TABLE IF NOT EXISTS click_rollups_daily (
+			link_id INTEGER REFERENCES links(id) ON DELETE CASCADE NOT NULL,
+			dimension VARCHAR(20) NOT NULL,
+			bucket TIMESTAMPTZ NOT NULL,
+			value TEXT NOT NULL,
+			clicks INTEGER NOT NULL,
+			PRIMARY KEY (link_id, dimension, bucket, value)
+		)`,
+		// clicks with id <= last_click_id are in the rollups; pending_click_id
+		// is the highest id seen at observed_at, folded in once it settles
+		`CREATE TABLE IF NOT EXISTS click_rollup_state (
+			id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+			last_click_id BIGINT NOT NULL DEFAULT 0,
+			pending_click_id BIGINT NOT NULL DEFAULT 0,
+			observed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`,
+		`INSERT INTO click_rollup_state DEFAULT VALUES ON CONFLICT DO NOTHING`,
+		// crawlers, unfurlers, monitors and prefetches; rolled up under
+		// bot:-prefixed dimensions
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS is_bot BOOLEAN NOT NULL DEFAULT false`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS browser_version VARCHAR(50)`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS os_version VARCHAR(50)`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS device_brand VARCHAR(50)`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS device_model VARCHAR(100)`,
+		`ALTER TABLE clicks ADD COLUMN IF NOT EXISTS engine VARCHAR(50)`,
 	}
 
 	for i, m := range migrations {
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/shortly/internal/models"
+	"github.com/shortly/internal/services"
+	"github.com/shortly/internal/utils"
+)
+
+var usedPage = template.Must(template.New("used").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Link already used</title>
+<style>
+body{font-family:system-ui,sans-serif;background:#f5f5f5;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0}
+main{background:#fff;padding:2rem;border-radius:8px;box-shadow:0 1px 4px rgba(0,0,0,.1);width:100%;max-width:320px}
+h1{font-size:1.2rem;margin:0 0 1rem}
+</style>
+</head>
+<body>
+<main>
+<h1>This link has already been used</h1>
+<p>It was a one-time link and can't be opened again. Ask the sender for a new one.</p>
+</main>
+</body>
+</html>
+`))
+
+// visit admits and records a visit to link and reports whether to send the
+// client on to the destination; if not, the response has been written.
+// Bots are only checked against click limits so they don't use them up,
+// and are never let through a single-use link.
+func (h *LinkHandler) visit(w http.ResponseWriter, r *http.Request, link *models.Link) bool {
+	bot := isBotRequest(r)
+	if bot && link.SingleUse {
+		// an unfurler or prefetch would burn the link for its recipient
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusNoContent)
+		return false
+	}
+	if bot && !admitted(w, h.links.AdmitBot(r.Context(), link)) {
+		return false
+	}
+	if !bot && !h.admit(w, r, link) {
+		return false
+	}
+	// queue the click; written in batches in the background
+	h.clicks.Record(link, clientIP(r), r.UserAgent(), r.Referer(), bot)
+	return true
+}
+
+// isBotRequest reports whether r comes from a crawler, link preview,
+// monitor or prefetch rather than a person following the link.
+func isBotRequest(r *http.Request) bool {
+	if r.Method == http.MethodHead || utils.IsBot(r.UserAgent()) {
+		return true
+	}
+	for _, name := range []string{"Purpose", "Sec-Purpose", "X-Purpose", "X-Moz"} {
+		v := strings.ToLower(r.Header.Get(name))
+		if strings.Contains(v, "prefetch") || strings.Contains(v, "preview") {
+			return true
+		}
+	}
+	return false
+}
+
+// admit claims this visit against the link's single-use flag and click
+// limit. It returns false after writing the response if the link ran out.
+func (h *LinkHandler) admit(w http.ResponseWriter, r *http.Request, link *models.Link) bool {
+	return admitted(w, h.links.Admit(r.Context(), link))
+}
+
+// admitted writes the response for a visit that Admit or AdmitBot turned
+// down and reports whether err let the visit through.
+func admitted(w http.ResponseWriter, err error) bool {
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, services.ErrLinkUsed):
+		renderUsedPage(w)
+	case errors.Is(err, services.ErrClickLimit):
+		writeError(w, err.Error(), http.StatusNotFound)
+	default:
+		writeError(w, "failed to open link", http.StatusInternalServerError)
+	}
+	return false
+}
+
+func renderUsedPage(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusGone)
+	usedPage.Execute(w, nil)
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/shortly/internal/services"
+)
+
+func TestAdmitted(t *testing.T) {
+	tests := []struct {
+		err    error
+		ok     bool
+		status int
+	}{
+		{nil, true, http.StatusOK},
+		// what a bot gets from AdmitBot on an exhausted link
+		{services.ErrClickLimit, false, http.StatusNotFound},
+		{services.ErrLinkUsed, false, http.StatusGone},
+		{errors.New("db down"), false, http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		if ok := admitted(w, tt.err); ok != tt.ok || w.Code != tt.status {
+			t.Errorf("admitted(%v) = %v with %d, want %v with %d", tt.err, ok, w.Code, tt.ok, tt.status)
+		}
+	}
+}
+
+func TestIsBotRequest(t *testing.T) {
+	tests := []struct {
+		method, ua, purpose string
+		want                bool
+	}{
+		{http.MethodGet, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", "", false},
+		{http.MethodHead, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", "", true},
+		{http.MethodGet, "Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", "", true},
+		{http.MethodGet, "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36", "prefetch", true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, "/abc", nil)
+		r.Header.Set("User-Agent", tt.ua)
+		if tt.purpose != "" {
+			r.Header.Set("Sec-Purpose", tt.purpose)
+		}
+		if got := isBotRequest(r); got != tt.want {
+			t.Errorf("isBotRequest(%s %q, purpose %q) = %v, want %v", tt.method, tt.ua, tt.purpose, got, tt.want)
+		}
+	}
+}
@@ -8,8 +8,11 @@ import (
 	"github.com/shortly/internal/models"
 )
 
+// BulkRequest creates many links at once. Mode "atomic" creates all of them
+// or none; the default "best_effort" creates every valid item.
 type BulkRequest struct {
 	URLs []models.CreateLinkRequest `json:"urls"`
+	Mode string                     `json:"mode,omitempty"`
 }
 
 type BulkResponse struct {
@@ -36,20 +39,29 @@ func (h *LinkHandler) BulkCreate(w http.ResponseWriter, r *http.Request) {
 		writeError(w, "urls array is empty", http.StatusBadRequest)
 		return
 	}
-	if len(req.URLs) > 50 {
-		writeError(w, "max 50 urls per batch", http.StatusBadRequest)
+	if req.Mode != "" && req.Mode != "atomic" && req.Mode != "best_effort" {
+		writeError(w, "mode must be atomic or best_effort", http.StatusBadRequest)
 		return
 	}
 
-	resp := BulkResponse{}
-	for i, item := range req.URLs {
-		link, err := h.links.Create(r.Context(), userID, item)
-		if err != nil {
-			resp.Errors = append(resp.Errors, BulkError{Index: i, URL: item.URL, Error: err.Error()})
-			continue
+	links, errs, err := h.links.BulkCreate(r.Context(), userID, req.URLs, req.Mode == "atomic")
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := BulkResponse{Links: []models.Link{}}
+	for i, link := range links {
+		if link != nil {
+			resp.Links = append(resp.Links, *link)
+		} else if errs[i] != nil {
+			resp.Errors = append(resp.Errors, BulkError{Index: i, URL: req.URLs[i].URL, Error: errs[i].Error()})
 		}
-		resp.Links = append(resp.Links, *link)
 	}
 
-	writeJSON(w, resp, http.StatusCreated)
+	status := http.StatusCreated
+	if len(resp.Links) == 0 {
+		status = http.StatusBadRequest
+	}
+	writeJSON(w, resp, status)
 }
@@ -0,0 +1,326 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/shortly/internal/importers"
+	"github.com/shortly/internal/middleware"
+	"github.com/shortly/internal/models"
+)
+
+// maxImportBytes caps the size of an import upload.
+const maxImportBytes = 20 << 20
+
+var csvHeader = []string{"short_code", "url", "title", "tags", "is_active", "expires_at", "max_clicks", "click_count", "created_at"}
+
+type ImportResponse struct {
+	Imported int           `json:"imported"`
+	Errors   []ImportError `json:"errors,omitempty"`
+}
+
+type ImportError struct {
+	Row       int    `json:"row"`
+	ShortCode string `json:"short_code,omitempty"`
+	Error     string `json:"error"`
+}
+
+// Export streams all of the user's links as csv, json or ndjson.
+// GET /api/links/export?format=csv|json|ndjson
+func (h *LinkHandler) Export(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = "json"
+	}
+
+	var write func(models.LinkRecord) error
+	var finish func() error
+	switch format {
+	case "csv":
+		w.Header().Set("Content-Type", "text/csv")
+		cw := csv.NewWriter(w)
+		cw.Write(csvHeader)
+		write = func(rec models.LinkRecord) error { return cw.Write(recordToCSV(rec)) }
+		finish = func() error { cw.Flush(); return cw.Error() }
+	case "json":
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		first := true
+		io.WriteString(w, "[")
+		write = func(rec models.LinkRecord) error {
+			if !first {
+				io.WriteString(w, ",")
+			}
+			first = false
+			return enc.Encode(rec)
+		}
+		finish = func() error { _, err := io.WriteString(w, "]\n"); return err }
+	case "ndjson":
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		enc := json.NewEncoder(w)
+		write = func(rec models.LinkRecord) error { return enc.Encode(rec) }
+		finish = func() error { return nil }
+	default:
+		writeError(w, "format must be csv, json or ndjson", http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="links.%s"`, format))
+
+	// headers are already sent once rows start streaming, so a failure
+	// midway can only cut the download short
+	if err := h.links.Export(r.Context(), userID, write); err != nil {
+		log.Println("export:", err)
+		return
+	}
+	finish()
+}
+
+// Import creates links from an upload in any export format. Every row goes
+// through the normal create validation; failures are reported per row.
+// POST /api/links/import?format=csv|json|ndjson
+func (h *LinkHandler) Import(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = formatFromContentType(r.Header.Get("Content-Type"))
+	}
+
+	body := http.MaxBytesReader(w, r.Body, maxImportBytes)
+	records, rowErrs, err := decodeRecords(body, format)
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	resp := ImportResponse{Errors: rowErrs}
+	for i, rec := range records {
+		if rec == nil {
+			continue // already reported as unparseable
+		}
+		if _, err := h.links.Import(r.Context(), userID, *rec); err != nil {
+			resp.Errors = append(resp.Errors, ImportError{Row: i + 1, ShortCode: rec.ShortCode, Error: err.Error()})
+			continue
+		}
+		resp.Imported++
+	}
+
+	status := http.StatusOK
+	if resp.Imported > 0 {
+		status = http.StatusCreated
+	}
+	writeJSON(w, resp, status)
+}
+
+func formatFromContentType(ct string) string {
+	switch {
+	case strings.HasPrefix(ct, "text/csv"):
+		return "csv"
+	case strings.HasPrefix(ct, "application/x-ndjson"), strings.HasPrefix(ct, "application/ndjson"):
+		return "ndjson"
+	default:
+		return "json"
+	}
+}
+
+// decodeRecords parses an upload into records numbered from row 1. Rows that
+// can't be parsed are returned as errors and left nil in records.
+func decodeRecords(r io.Reader, format string) ([]*models.LinkRecord, []ImportError, error) {
+	switch format {
+	case "json":
+		var raw []json.RawMessage
+		if err := json.NewDecoder(r).Decode(&raw); err != nil {
+			return nil, nil, errors.New("invalid json: expected an array of links")
+		}
+		records := make([]*models.LinkRecord, len(raw))
+		var rowErrs []ImportError
+		for i, item := range raw {
+			rec, err := recordFromJSON(item)
+			if err != nil {
+				rowErrs = append(rowErrs, ImportError{Row: i + 1, Error: err.Error()})
+				continue
+			}
+			records[i] = rec
+		}
+		return records, rowErrs, nil
+
+	case "ndjson":
+		var records []*models.LinkRecord
+		var rowErrs []ImportError
+		sc := bufio.NewScanner(r)
+		sc.Buffer(make([]byte, 64*1024), 1<<20)
+		for sc.Scan() {
+			line := strings.TrimSpace(sc.Text())
+			if line == "" {
+				continue
+			}
+			rec, err := recordFromJSON([]byte(line))
+			if err != nil {
+				rowErrs = append(rowErrs, ImportError{Row: len(records) + 1, Error: err.Error()})
+			}
+			records = append(records, rec)
+		}
+		if err := sc.Err(); err != nil {
+			return nil, nil, err
+		}
+		return records, rowErrs, nil
+
+	case "csv":
+		cr := csv.NewReader(r)
+		cr.FieldsPerRecord = -1
+		header, err := cr.Read()
+		if err != nil {
+			return nil, nil, errors.New("invalid csv: missing header row")
+		}
+		cols := map[string]int{}
+		for i, name := range header {
+			cols[strings.ToLower(strings.TrimSpace(name))] = i
+		}
+		if _, ok := cols["url"]; !ok {
+			return nil, nil, errors.New("invalid csv: url column is required")
+		}
+
+		var records []*models.LinkRecord
+		var rowErrs []ImportError
+		for {
+			row, err := cr.Read()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				var perr *csv.ParseError
+				if errors.As(err, &perr) {
+					rowErrs = append(rowErrs, ImportError{Row: len(records) + 1, Error: perr.Err.Error()})
+					records = append(records, nil)
+					continue
+				}
+				return nil, nil, err
+			}
+			rec, err := recordFromCSV(row, cols)
+			if err != nil {
+				rowErrs = append(rowErrs, ImportError{Row: len(records) + 1, ShortCode: rec.ShortCode, Error: err.Error()})
+				records = append(records, nil)
+				continue
+			}
+			records = append(records, &rec)
+		}
+		return records, rowErrs, nil
+
+	default:
+		return nil, nil, errors.New("format must be csv, json or ndjson")
+	}
+}
+
+// recordFromJSON decodes one record; is_active defaults to true when absent.
+func recordFromJSON(data []byte) (*models.LinkRecord, error) {
+	rec := &models.LinkRecord{IsActive: true}
+	if err := json.Unmarshal(data, rec); err != nil {
+		return nil, errors.New("invalid json")
+	}
+	return rec, nil
+}
+
+func recordToCSV(rec models.LinkRecord) []string {
+	var expiresAt, maxClicks string
+	if rec.ExpiresAt != nil {
+		expiresAt = rec.ExpiresAt.Format(time.RFC3339)
+	}
+	if rec.MaxClicks != nil {
+		maxClicks = strconv.Itoa(*rec.MaxClicks)
+	}
+	return []string{
+		rec.ShortCode,
+		rec.URL,
+		rec.Title,
+		strings.Join(rec.Tags, "|"),
+		strconv.FormatBool(rec.IsActive),
+		expiresAt,
+		maxClicks,
+		strconv.Itoa(rec.ClickCount),
+		rec.CreatedAt.Format(time.RFC3339),
+	}
+}
+
+// recordFromCSV maps a row by header name; only url is required and unknown
+// columns are ignored. Tags are separated by "|".
+func recordFromCSV(row []string, cols map[string]int) (models.LinkRecord, error) {
+	get := func(name string) string {
+		if i, ok := cols[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	rec := models.LinkRecord{
+		ShortCode: get("short_code"),
+		URL:       get("url"),
+		Title:     get("title"),
+		IsActive:  true,
+	}
+	if tags := get("tags"); tags != "" {
+		rec.Tags = strings.Split(tags, "|")
+	}
+	if v := get("is_active"); v != "" {
+		active, err := strconv.ParseBool(v)
+		if err != nil {
+			return rec, errors.New("invalid is_active")
+		}
+		rec.IsActive = active
+	}
+	if v := get("expires_at"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return rec, errors.New("invalid expires_at: use RFC 3339")
+		}
+		rec.ExpiresAt = &t
+	}
+	if v := get("max_clicks"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			return rec, errors.New("invalid max_clicks")
+		}
+		rec.MaxClicks = &n
+	}
+	if rec.URL == "" {
+		return rec, errors.New("url is required")
+	}
+	return rec, nil
+}
+
+// ImportExternal imports another shortener's export, keeping its short codes.
+// POST /api/links/import/{source}?seed_clicks=true with source bitly, yourls or kutt
+func (h *LinkHandler) ImportExternal(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	source := chi.URLParam(r, "source")
+	seedClicks, _ := strconv.ParseBool(r.URL.Query().Get("seed_clicks"))
+
+	records, err := importers.Parse(source, http.MaxBytesReader(w, r.Body, maxImportBytes))
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report, err := h.links.ImportExternal(r.Context(), userID, source, records, seedClicks)
+	if err != nil {
+		writeError(w, "error importing links", http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if report.Imported > 0 {
+		status = http.StatusCreated
+	}
+	writeJSON(w, report, status)
+}
@@ -2,23 +2,28 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 
 	"github.com/shortly/internal/middleware"
 	"github.com/shortly/internal/models"
 	"github.com/shortly/internal/services"
+	"github.com/shortly/internal/utils"
 )
 
 type LinkHandler struct {
 	links  *services.LinkService
 	clicks *services.ClickService
+	guard  *services.UnlockGuard
 }
 
-func NewLinkHandler(links *services.LinkService, clicks *services.ClickService) *LinkHandler {
-	return &LinkHandler{links: links, clicks: clicks}
+func NewLinkHandler(links *services.LinkService, clicks *services.ClickService, guard *services.UnlockGuard) *LinkHandler {
+	return &LinkHandler{links: links, clicks: clicks, guard: guard}
 }
 
 func (h *LinkHandler) Create(w http.ResponseWriter, r *http.Request) {
@@ -50,8 +55,24 @@ func (h *LinkHandler) List(w http.ResponseWriter, r *http.Request) {
 		perPage = 20
 	}
 
-	resp, err := h.links.ListByUser(r.Context(), userID, page, perPage)
+	filter, err := parseLinkFilter(r)
 	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// cursor mode: ?cursor= (empty for the first page) switches to keyset pagination
+	var resp *models.LinkListResponse
+	if r.URL.Query().Has("cursor") {
+		resp, err = h.links.ListByUserCursor(r.Context(), userID, filter, r.URL.Query().Get("cursor"), perPage)
+	} else {
+		resp, err = h.links.ListByUser(r.Context(), userID, filter, page, perPage)
+	}
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		writeError(w, "error fetching links", http.StatusInternalServerError)
 		return
 	}
@@ -59,6 +80,204 @@ func (h *LinkHandler) List(w http.ResponseWriter, r *http.Request) {
 	writeJSON(w, resp, http.StatusOK)
 }
 
+func (h *LinkHandler) ListClicks(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	limit, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if limit < 1 || limit > 500 {
+		limit = 100
+	}
+
+	if !h.links.Owns(r.Context(), linkID, userID) {
+		writeError(w, services.ErrNotFound.Error(), http.StatusNotFound)
+		return
+	}
+
+	resp, err := h.clicks.List(r.Context(), linkID, r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		if errors.Is(err, utils.ErrInvalidCursor) {
+			writeError(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeError(w, "error fetching clicks", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp, http.StatusOK)
+}
+
+func (h *LinkHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.UpdateLinkRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.links.Update(r.Context(), linkID, userID, req)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, link, http.StatusOK)
+}
+
+func (h *LinkHandler) History(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	revisions, err := h.links.History(r.Context(), linkID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, "error fetching history", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, revisions, http.StatusOK)
+}
+
+func (h *LinkHandler) RestoreRevision(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	rev, err := strconv.Atoi(chi.URLParam(r, "rev"))
+	if err != nil {
+		writeError(w, "invalid revision", http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.links.RestoreRevision(r.Context(), linkID, userID, rev)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, link, http.StatusOK)
+}
+
+func (h *LinkHandler) AddTags(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.LinkTagsRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || len(req.Tags) == 0 {
+		writeError(w, "tags array is required", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := h.links.AddTags(r.Context(), linkID, userID, req.Tags)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, "error tagging link", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, tags, http.StatusOK)
+}
+
+func (h *LinkHandler) RemoveTag(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	tagID, err := strconv.Atoi(chi.URLParam(r, "tagID"))
+	if err != nil {
+		writeError(w, "invalid tag id", http.StatusBadRequest)
+		return
+	}
+
+	tags, err := h.links.RemoveTag(r.Context(), linkID, userID, tagID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, "error untagging link", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, tags, http.StatusOK)
+}
+
+func (h *LinkHandler) ListTrash(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+	if page < 1 {
+		page = 1
+	}
+	if perPage < 1 || perPage > 100 {
+		perPage = 20
+	}
+
+	resp, err := h.links.ListTrash(r.Context(), userID, page, perPage)
+	if err != nil {
+		writeError(w, "error fetching trash", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, resp, http.StatusOK)
+}
+
+func (h *LinkHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.links.Restore(r.Context(), linkID, userID)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, "error restoring link", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, link, http.StatusOK)
+}
+
 func (h *LinkHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	userID := middleware.GetUserID(r.Context())
 	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
@@ -72,40 +291,102 @@ func (h *LinkHandler) Delete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	writeJSON(w, map[string]string{"msg": "deleted"}, http.StatusOK)
+	writeJSON(w, map[string]string{"msg": "moved to trash"}, http.StatusOK)
 }
 
 func (h *LinkHandler) Redirect(w http.ResponseWriter, r *http.Request) {
 	code := chi.URLParam(r, "code")
 
-	url, linkID, err := h.links.Resolve(r.Context(), code)
+	link, err := h.links.Resolve(r.Context(), code)
 	if err != nil {
+		if errors.Is(err, services.ErrLinkUsed) {
+			renderUsedPage(w)
+			return
+		}
 		writeError(w, err.Error(), http.StatusNotFound)
 		return
 	}
 
-	// record click async
-	go h.clicks.Record(r.Context(), linkID, r.RemoteAddr, r.UserAgent(), r.Referer())
+	if link.HasPassword {
+		h.serveProtected(w, r, link)
+		return
+	}
+	if !h.visit(w, r, link) {
+		return
+	}
 
-	http.Redirect(w, r, url, http.StatusMovedPermanently)
+	if link.SingleUse {
+		// a permanent redirect would let the browser skip us next time
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, link.OriginalURL, http.StatusFound)
+		return
+	}
+	http.Redirect(w, r, link.OriginalURL, http.StatusMovedPermanently)
 }
 
 func (h *LinkHandler) GetStats(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
 	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
 	if err != nil {
 		writeError(w, "invalid id", http.StatusBadRequest)
 		return
 	}
-	days, _ := strconv.Atoi(r.URL.Query().Get("days"))
-	if days < 1 || days > 365 {
-		days = 30
+	q, err := parseStatsQuery(r, time.Now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
 	}
 
-	stats, err := h.clicks.GetStats(r.Context(), linkID, days)
+	stats, err := h.clicks.GetStats(r.Context(), linkID, userID, q)
 	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
 		writeError(w, "error", http.StatusInternalServerError)
 		return
 	}
 
 	writeJSON(w, stats, http.StatusOK)
 }
+
+// parseLinkFilter reads the search, filter and sort query parameters of
+// GET /api/links. Dates accept RFC 3339 or YYYY-MM-DD (UTC). created_to is
+// exclusive, except that a plain date includes that whole day.
+func parseLinkFilter(r *http.Request) (models.LinkFilter, error) {
+	q := r.URL.Query()
+	f := models.LinkFilter{
+		Query:  q.Get("q"),
+		Tag:    q.Get("tag"),
+		Status: q.Get("status"),
+		Domain: q.Get("domain"),
+		Sort:   q.Get("sort"),
+		Order:  q.Get("order"),
+	}
+
+	for param, dest := range map[string]**time.Time{"created_from": &f.CreatedAfter, "created_to": &f.CreatedBefore} {
+		v := q.Get(param)
+		if v == "" {
+			continue
+		}
+		t, dateOnly, err := parseDateParam(v)
+		if err != nil {
+			return f, fmt.Errorf("invalid %s", param)
+		}
+		if dateOnly && param == "created_to" {
+			t = t.AddDate(0, 0, 1)
+		}
+		*dest = &t
+	}
+
+	return f, services.ValidateLinkFilter(f)
+}
+
+// parseDateParam parses RFC 3339 or YYYY-MM-DD and reports which it was.
+func parseDateParam(v string) (time.Time, bool, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, false, nil
+	}
+	t, err := time.Parse("2006-01-02", v)
+	return t, true, err
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestParseLinkFilterDates(t *testing.T) {
+	tests := []struct {
+		query      string
+		from, to   string // RFC 3339, empty for unset
+		wantsError bool
+	}{
+		// a plain date includes the whole day it names
+		{query: "created_from=2024-05-01&created_to=2024-05-31", from: "2024-05-01T00:00:00Z", to: "2024-06-01T00:00:00Z"},
+		// an exact time is used as given, to stays exclusive
+		{query: "created_to=2024-05-31T12:00:00Z", to: "2024-05-31T12:00:00Z"},
+		{query: "created_from=2024-05-01T08:00:00%2B02:00", from: "2024-05-01T06:00:00Z"},
+		{query: "created_to=31.05.2024", wantsError: true},
+	}
+	for _, tt := range tests {
+		f, err := parseLinkFilter(httptest.NewRequest("GET", "/api/links?"+tt.query, nil))
+		if tt.wantsError {
+			if err == nil {
+				t.Errorf("%s: no error", tt.query)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		check := func(name string, got *time.Time, want string) {
+			switch {
+			case want == "" && got != nil:
+				t.Errorf("%s: %s = %v, want unset", tt.query, name, got)
+			case want != "" && (got == nil || !got.Equal(mustTime(t, want))):
+				t.Errorf("%s: %s = %v, want %s", tt.query, name, got, want)
+			}
+		}
+		check("created_from", f.CreatedAfter, tt.from)
+		check("created_to", f.CreatedBefore, tt.to)
+	}
+}
+
+func mustTime(t *testing.T, v string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
@@ -0,0 +1,40 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/shortly/internal/services"
+)
+
+type MetricsHandler struct {
+	clicks *services.ClickService
+}
+
+func NewMetricsHandler(clicks *services.ClickService) *MetricsHandler {
+	return &MetricsHandler{clicks: clicks}
+}
+
+// Serve writes pipeline counters in the Prometheus text format.
+// GET /metrics on METRICS_ADDR
+func (h *MetricsHandler) Serve(w http.ResponseWriter, r *http.Request) {
+	s := h.clicks.QueueStats()
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	for _, m := range []struct {
+		name, kind, help string
+		value            int64
+	}{
+		{"shortly_click_queue_depth", "gauge", "Clicks waiting to be written.", int64(s.Depth)},
+		{"shortly_click_queue_capacity", "gauge", "Size of the click queue.", int64(s.Capacity)},
+		{"shortly_clicks_enqueued_total", "counter", "Clicks accepted into the queue.", s.Enqueued},
+		{"shortly_clicks_dropped_total", "counter", "Clicks dropped because the queue was full.", s.Dropped},
+		{"shortly_clicks_written_total", "counter", "Clicks written to the database.", s.Written},
+		{"shortly_clicks_failed_total", "counter", "Clicks in batches that failed to write.", s.Failed},
+		{"shortly_click_batches_total", "counter", "Click batches flushed.", s.Batches},
+		{"shortly_clicks_spooled_total", "counter", "Clicks spooled to disk because the database write failed.", s.Spooled},
+		{"shortly_clicks_replayed_total", "counter", "Spooled clicks written once the database was back.", s.Replayed},
+		{"shortly_click_spool_bytes", "gauge", "Bytes waiting in the click spool.", s.SpoolBytes},
+	} {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n%s %d\n", m.name, m.help, m.name, m.kind, m.name, m.value)
+	}
+}
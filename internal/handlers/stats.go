@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/shortly/internal/middleware"
+	"github.com/shortly/internal/models"
+	"github.com/shortly/internal/services"
+)
+
+// StatsHandler serves analytics across many links. Single-link stats live
+// on LinkHandler.
+type StatsHandler struct {
+	clicks *services.ClickService
+}
+
+func NewStatsHandler(clicks *services.ClickService) *StatsHandler {
+	return &StatsHandler{clicks: clicks}
+}
+
+func (h *StatsHandler) Overview(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	q, err := parseStatsQuery(r, time.Now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.clicks.OverviewStats(r.Context(), userID, q)
+	if err != nil {
+		writeError(w, "error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, stats, http.StatusOK)
+}
+
+func (h *StatsHandler) Tag(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	tagID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+	q, err := parseStatsQuery(r, time.Now())
+	if err != nil {
+		writeError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.clicks.TagStats(r.Context(), tagID, userID, q)
+	if err != nil {
+		if errors.Is(err, services.ErrNotFound) {
+			writeError(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeError(w, "error", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, stats, http.StatusOK)
+}
+
+// parseStatsQuery reads the window and bucketing of a stats request. A
+// YYYY-MM-DD from/to is a whole day in tz, so to is inclusive; RFC 3339
+// times are used as given and to is exclusive. Without either, the window
+// is the last days days (default 30) ending with today and the totals are
+// all-time, unless compare asks for a comparison of the window against the
+// previous period or one starting at the given date.
+func parseStatsQuery(r *http.Request, now time.Time) (models.StatsQuery, error) {
+	v := r.URL.Query()
+	q := models.StatsQuery{Location: time.UTC, Interval: "day"}
+	if tz := v.Get("tz"); tz != "" {
+		// "Local" means the server's zone to Go but nothing to postgres
+		loc, err := time.LoadLocation(tz)
+		if err != nil || tz == "Local" {
+			return q, fmt.Errorf("unknown tz %q", tz)
+		}
+		q.Location = loc
+	}
+	if i := v.Get("interval"); i != "" {
+		q.Interval = i
+	}
+
+	y, m, d := now.In(q.Location).Date()
+	q.To = time.Date(y, m, d+1, 0, 0, 0, 0, q.Location)
+	if s := v.Get("to"); s != "" {
+		t, err := parseStatsTime(s, q.Location)
+		if err != nil {
+			return q, errors.New("invalid to")
+		}
+		if len(s) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		q.To, q.Bounded = t, true
+	}
+	if s := v.Get("from"); s != "" {
+		t, err := parseStatsTime(s, q.Location)
+		if err != nil {
+			return q, errors.New("invalid from")
+		}
+		q.From, q.Bounded = t, true
+	} else {
+		days, _ := strconv.Atoi(v.Get("days"))
+		if days < 1 || days > 365 {
+			days = 30
+		}
+		q.From = q.To.AddDate(0, 0, -days)
+	}
+
+	if b := v.Get("include_bots"); b != "" {
+		include, err := strconv.ParseBool(b)
+		if err != nil {
+			return q, errors.New("include_bots must be true or false")
+		}
+		q.IncludeBots = include
+	}
+
+	switch c := v.Get("compare"); c {
+	case "":
+	case "previous":
+		q.Compare, q.Bounded = true, true
+	default:
+		t, err := parseStatsTime(c, q.Location)
+		if err != nil {
+			return q, errors.New("compare must be previous or a start date")
+		}
+		q.Compare, q.Bounded, q.CompareFrom = true, true, t
+	}
+
+	return q, services.ValidateStatsQuery(q)
+}
+
+func parseStatsTime(v string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+	return time.ParseInLocation("2006-01-02", v, loc)
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/shortly/internal/middleware"
+	"github.com/shortly/internal/models"
+	"github.com/shortly/internal/services"
+)
+
+type TagHandler struct {
+	tags *services.TagService
+}
+
+func NewTagHandler(tags *services.TagService) *TagHandler {
+	return &TagHandler{tags: tags}
+}
+
+func (h *TagHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+
+	tags, err := h.tags.List(r.Context(), userID)
+	if err != nil {
+		writeError(w, "error fetching tags", http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, tags, http.StatusOK)
+}
+
+func (h *TagHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+
+	var req models.TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.tags.Create(r.Context(), userID, req.Name)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+
+	writeJSON(w, tag, http.StatusCreated)
+}
+
+func (h *TagHandler) Rename(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	tagID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.TagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.tags.Rename(r.Context(), tagID, userID, req.Name)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+
+	writeJSON(w, tag, http.StatusOK)
+}
+
+func (h *TagHandler) Merge(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	tagID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	var req models.MergeTagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tag, err := h.tags.Merge(r.Context(), tagID, req.Into, userID)
+	if err != nil {
+		writeTagError(w, err)
+		return
+	}
+
+	writeJSON(w, tag, http.StatusOK)
+}
+
+func (h *TagHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	tagID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid id", http.StatusBadRequest)
+		return
+	}
+
+	if err := h.tags.Delete(r.Context(), tagID, userID); err != nil {
+		writeTagError(w, err)
+		return
+	}
+
+	writeJSON(w, map[string]string{"msg": "deleted"}, http.StatusOK)
+}
+
+func writeTagError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, services.ErrNotFound):
+		writeError(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, services.ErrTagExists):
+		writeError(w, err.Error(), http.StatusConflict)
+	default:
+		writeError(w, err.Error(), http.StatusBadRequest)
+	}
+}
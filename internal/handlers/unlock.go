@@ -0,0 +1,196 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+
+	"github.com/shortly/internal/middleware"
+	"github.com/shortly/internal/models"
+	"github.com/shortly/internal/services"
+)
+
+type PasswordRequest struct {
+	Password string `json:"password"`
+}
+
+var unlockPage = template.Must(template.New("unlock").Parse(`<!doctype html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta name="robots" content="noindex">
+<title>Protected link</title>
+<style>
+body{font-family:system-ui,sans-serif;background:#f5f5f5;display:flex;align-items:center;justify-content:center;min-height:100vh;margin:0}
+form{background:#fff;padding:2rem;border-radius:8px;box-shadow:0 1px 4px rgba(0,0,0,.1);width:100%;max-width:320px}
+h1{font-size:1.2rem;margin:0 0 1rem}
+input,button{width:100%;box-sizing:border-box;padding:.6rem;margin-top:.5rem;font-size:1rem}
+.error{color:#b00020;margin:.5rem 0 0}
+</style>
+</head>
+<body>
+<form method="post" action="/{{.Code}}/unlock">
+<h1>This link is password protected</h1>
+<input type="password" name="password" placeholder="Password" autofocus required>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<button type="submit">Continue</button>
+</form>
+</body>
+</html>
+`))
+
+// serveProtected redirects a password-protected link if the request carries
+// a valid unlock cookie and shows the unlock form otherwise.
+func (h *LinkHandler) serveProtected(w http.ResponseWriter, r *http.Request, link *models.Link) {
+	if c, err := r.Cookie(unlockCookieName(link.ShortCode)); err == nil && h.links.ValidUnlockToken(link, c.Value) {
+		if !h.visit(w, r, link) {
+			return
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		http.Redirect(w, r, link.OriginalURL, http.StatusFound)
+		return
+	}
+	renderUnlockPage(w, link.ShortCode, "", http.StatusOK)
+}
+
+// Unlock verifies the password of a protected link and, on success, sets a
+// short-lived signed cookie so repeat visits skip the prompt. Accepts the
+// HTML form or JSON {"password": "..."}; JSON callers get {"url": "..."} back.
+// POST /{code}/unlock
+func (h *LinkHandler) Unlock(w http.ResponseWriter, r *http.Request) {
+	code := chi.URLParam(r, "code")
+	wantsJSON := strings.HasPrefix(r.Header.Get("Content-Type"), "application/json")
+
+	var password string
+	if wantsJSON {
+		var req PasswordRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		password = req.Password
+	} else {
+		password = r.PostFormValue("password")
+	}
+
+	link, err := h.links.Resolve(r.Context(), code)
+	if err != nil {
+		if errors.Is(err, services.ErrLinkUsed) {
+			renderUsedPage(w)
+			return
+		}
+		writeError(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	ip := clientIP(r)
+	if link.HasPassword {
+		if wait := h.guard.Check(r.Context(), code, ip); wait > 0 {
+			secs := int((wait + time.Second - 1) / time.Second)
+			w.Header().Set("Retry-After", strconv.Itoa(secs))
+			if wantsJSON {
+				writeError(w, fmt.Sprintf("too many attempts, try again in %d seconds", secs), http.StatusTooManyRequests)
+				return
+			}
+			renderUnlockPage(w, code, fmt.Sprintf("Too many attempts, try again in %d seconds.", secs), http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	if link.HasPassword && !h.links.VerifyPassword(r.Context(), link.ID, password) {
+		h.guard.Failed(r.Context(), link, ip, r.UserAgent())
+		if wantsJSON {
+			writeError(w, "wrong password", http.StatusForbidden)
+			return
+		}
+		renderUnlockPage(w, code, "Wrong password, try again.", http.StatusForbidden)
+		return
+	}
+
+	if link.HasPassword {
+		h.guard.Succeeded(r.Context(), ip)
+	}
+	// the visit is counted by the successful unlock, not by showing the form
+	if !h.admit(w, r, link) {
+		return
+	}
+
+	if link.HasPassword && !link.SingleUse {
+		http.SetCookie(w, &http.Cookie{
+			Name:     unlockCookieName(code),
+			Value:    h.links.UnlockToken(link),
+			Path:     "/" + code,
+			Expires:  time.Now().Add(services.UnlockTTL),
+			MaxAge:   int(services.UnlockTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https",
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	// record click; whoever knew the password is not a crawler
+	h.clicks.Record(link, clientIP(r), r.UserAgent(), r.Referer(), false)
+
+	w.Header().Set("Cache-Control", "no-store")
+	if wantsJSON {
+		writeJSON(w, map[string]string{"url": link.OriginalURL}, http.StatusOK)
+		return
+	}
+	http.Redirect(w, r, link.OriginalURL, http.StatusSeeOther)
+}
+
+// SecurityEvents lists failed and locked-out unlock attempts on a link.
+// GET /api/links/{id}/security-events?limit=50
+func (h *LinkHandler) SecurityEvents(w http.ResponseWriter, r *http.Request) {
+	userID := middleware.GetUserID(r.Context())
+	linkID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		writeError(w, "invalid link id", http.StatusBadRequest)
+		return
+	}
+	if !h.links.Owns(r.Context(), linkID, userID) {
+		writeError(w, "link not found", http.StatusNotFound)
+		return
+	}
+
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	if limit < 1 || limit > 500 {
+		limit = 50
+	}
+
+	events, err := h.guard.Events(r.Context(), linkID, limit)
+	if err != nil {
+		writeError(w, "failed to load security events", http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, events, http.StatusOK)
+}
+
+// clientIP strips the port from RemoteAddr, which RealIP may or may not
+// have already replaced with a bare address.
+func clientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
+
+func unlockCookieName(code string) string {
+	return "shortly_unlock_" + code
+}
+
+func renderUnlockPage(w http.ResponseWriter, code, errMsg string, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	unlockPage.Execute(w, struct{ Code, Error string }{code, errMsg})
+}
@@ -0,0 +1,98 @@
+package importers
+
+import (
+	"encoding/csv"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// bitlyColumns maps the header names seen across Bitly CSV exports to fields.
+// Headers are lower-cased with underscores read as spaces before lookup.
+var bitlyColumns = map[string]string{
+	"long url":          "url",
+	"destination":       "url",
+	"destination url":   "url",
+	"original url":      "url",
+	"bitlink":           "link",
+	"link":              "link",
+	"short link":        "link",
+	"short url":         "link",
+	"custom link":       "custom",
+	"custom bitlink":    "custom",
+	"title":             "title",
+	"created":           "created",
+	"created at":        "created",
+	"date created":      "created",
+	"clicks":            "clicks",
+	"total clicks":      "clicks",
+	"engagements":       "clicks",
+	"total engagements": "clicks",
+	"tags":              "tags",
+}
+
+// ParseBitly reads a Bitly links CSV export. Columns are matched by header
+// name; a custom back-half takes precedence over the generated bitlink.
+func ParseBitly(r io.Reader) ([]Record, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, errors.New("bitly: missing header row")
+	}
+	cols := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		name = strings.ReplaceAll(name, "_", " ")
+		if field, ok := bitlyColumns[name]; ok {
+			if _, dup := cols[field]; !dup {
+				cols[field] = i
+			}
+		}
+	}
+	if _, ok := cols["url"]; !ok {
+		return nil, errors.New("bitly: no long url column")
+	}
+
+	var records []Record
+	for {
+		row, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		get := func(field string) string {
+			if i, ok := cols[field]; ok && i < len(row) {
+				return strings.TrimSpace(row[i])
+			}
+			return ""
+		}
+
+		rec := Record{URL: get("url"), Title: get("title"), CreatedAt: parseTime(get("created"))}
+		if rec.URL == "" {
+			continue
+		}
+		rec.ShortCode = lastSegment(get("custom"))
+		if rec.ShortCode == "" {
+			rec.ShortCode = lastSegment(get("link"))
+		}
+		if n, err := strconv.Atoi(strings.ReplaceAll(get("clicks"), ",", "")); err == nil {
+			rec.Clicks = n
+		}
+		for _, tag := range strings.FieldsFunc(get("tags"), func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				rec.Tags = append(rec.Tags, tag)
+			}
+		}
+		records = append(records, rec)
+	}
+	if len(records) == 0 {
+		return nil, errEmpty
+	}
+	return records, nil
+}
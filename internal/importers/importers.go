@@ -0,0 +1,129 @@
+// Package importers reads link exports from other URL shorteners.
+package importers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Record is one link read from another shortener's export.
+type Record struct {
+	ShortCode string     `json:"short_code"`
+	URL       string     `json:"url"`
+	Title     string     `json:"title,omitempty"`
+	Tags      []string   `json:"tags,omitempty"`
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+	Clicks    int        `json:"clicks"`
+}
+
+// Sources lists the accepted values for Parse.
+var Sources = []string{"bitly", "yourls", "kutt"}
+
+// Parse reads an export from source: a Bitly CSV export, a YOURLS SQL dump
+// or JSON (API stats output or a plain array), or a Kutt JSON export.
+func Parse(source string, r io.Reader) ([]Record, error) {
+	switch source {
+	case "bitly":
+		return ParseBitly(r)
+	case "yourls":
+		return ParseYOURLS(r)
+	case "kutt":
+		return ParseKutt(r)
+	default:
+		return nil, fmt.Errorf("unknown source %q: use one of %s", source, strings.Join(Sources, ", "))
+	}
+}
+
+var errEmpty = errors.New("export contains no links")
+
+// lastSegment returns the short code out of a short URL such as
+// "https://bit.ly/abc123" or a bare "abc123".
+func lastSegment(s string) string {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexAny(s, "?#"); i >= 0 {
+		s = s[:i]
+	}
+	s = strings.TrimRight(s, "/")
+	if i := strings.LastIndex(s, "/"); i >= 0 {
+		s = s[i+1:]
+	}
+	return s
+}
+
+var timeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"1/2/2006 15:04:05",
+	"1/2/2006 15:04",
+	"1/2/2006",
+}
+
+// parseTime accepts the date formats the supported exports use, read as UTC
+// unless they carry an offset.
+func parseTime(s string) *time.Time {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil
+	}
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return &t
+		}
+	}
+	if n, err := strconv.ParseInt(s, 10, 64); err == nil && n > 0 {
+		t := time.Unix(n, 0).UTC()
+		return &t
+	}
+	return nil
+}
+
+// sortRecords orders records by short code, for sources that come keyed
+// by an unordered map.
+func sortRecords(records []Record) {
+	sort.Slice(records, func(i, j int) bool { return records[i].ShortCode < records[j].ShortCode })
+}
+
+// flexInt decodes a JSON number or a numeric string; both show up in
+// shortener APIs for click counts.
+type flexInt int
+
+func (n *flexInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*n = 0
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid count %s", data)
+	}
+	*n = flexInt(v)
+	return nil
+}
+
+// decodeJSONList decodes either a bare array or an object wrapping the
+// array under key.
+func decodeJSONList(data []byte, key string, dest interface{}) error {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "[") {
+		return json.Unmarshal(data, dest)
+	}
+	var wrapper map[string]json.RawMessage
+	if err := json.Unmarshal(data, &wrapper); err != nil {
+		return err
+	}
+	list, ok := wrapper[key]
+	if !ok {
+		return fmt.Errorf("expected an array or an object with %q", key)
+	}
+	return json.Unmarshal(list, dest)
+}
@@ -0,0 +1,116 @@
+package importers
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseBitly(t *testing.T) {
+	csv := "\ufeffTitle,Bitlink,Custom bitlink,Long URL,Date created,Total clicks,Tags\n" +
+		"Spring sale,https://bit.ly/3xYz12,,https://example.com/spring,2024-03-01 10:00:00,\"1,204\",\"promo, spring\"\n" +
+		"Docs,https://bit.ly/4abc,https://bit.ly/our-docs,https://example.com/docs,2024-03-02,17,\n" +
+		",,,,,,\n"
+
+	records, err := ParseBitly(strings.NewReader(csv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+
+	r := records[0]
+	if r.ShortCode != "3xYz12" || r.URL != "https://example.com/spring" || r.Title != "Spring sale" || r.Clicks != 1204 {
+		t.Errorf("record 0 = %+v", r)
+	}
+	if len(r.Tags) != 2 || r.Tags[0] != "promo" || r.Tags[1] != "spring" {
+		t.Errorf("record 0 tags = %q", r.Tags)
+	}
+	if r.CreatedAt == nil || r.CreatedAt.Format("2006-01-02") != "2024-03-01" {
+		t.Errorf("record 0 created_at = %v", r.CreatedAt)
+	}
+	if records[1].ShortCode != "our-docs" {
+		t.Errorf("custom back-half should win, got %q", records[1].ShortCode)
+	}
+}
+
+func TestParseBitlyMissingURLColumn(t *testing.T) {
+	if _, err := ParseBitly(strings.NewReader("title,bitlink\nx,https://bit.ly/x\n")); err == nil {
+		t.Error("expected error for export without a long url column")
+	}
+}
+
+func TestParseYOURLSDump(t *testing.T) {
+	dump := "-- MySQL dump\n" +
+		"INSERT INTO `yourls_options` VALUES (1,'version','1.9');\n" +
+		"INSERT INTO `yourls_url` VALUES ('gh','https://github.com','GitHub','2023-05-01 08:00:00','1.2.3.4',42)," +
+		"('it''s','https://example.com/a?b=1,2','Quote \\'test\\'','2023-05-02 09:30:00','::1',0);\n" +
+		"INSERT INTO `shortener`.`yourls_url` (`url`, `keyword`, `clicks`) VALUES ('https://go.dev', 'go', NULL);\n"
+
+	records, err := ParseYOURLS(strings.NewReader(dump))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Record{
+		"gh":   {ShortCode: "gh", URL: "https://github.com", Title: "GitHub", Clicks: 42},
+		"it's": {ShortCode: "it's", URL: "https://example.com/a?b=1,2", Title: "Quote 'test'"},
+		"go":   {ShortCode: "go", URL: "https://go.dev"},
+	}
+	if len(records) != len(want) {
+		t.Fatalf("got %d records, want %d: %+v", len(records), len(want), records)
+	}
+	for _, r := range records {
+		w, ok := want[r.ShortCode]
+		if !ok || r.URL != w.URL || r.Title != w.Title || r.Clicks != w.Clicks {
+			t.Errorf("unexpected record %+v", r)
+		}
+	}
+}
+
+func TestParseYOURLSJSON(t *testing.T) {
+	stats := `{"links": {
+		"link_1": {"shorturl": "https://sho.rt/b", "url": "https://b.example", "title": "B", "timestamp": "2023-01-02 03:04:05", "clicks": "7"},
+		"link_2": {"shorturl": "https://sho.rt/a", "url": "https://a.example", "title": "A", "timestamp": "2023-01-01 00:00:00", "clicks": 3}
+	}, "stats": {"total_links": "2"}}`
+
+	records, err := ParseYOURLS(strings.NewReader(stats))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 || records[0].ShortCode != "a" || records[0].Clicks != 3 || records[1].Clicks != 7 {
+		t.Errorf("records = %+v", records)
+	}
+
+	list := `[{"keyword": "x", "url": "https://x.example", "clicks": 1}]`
+	records, err = ParseYOURLS(strings.NewReader(list))
+	if err != nil || len(records) != 1 || records[0].ShortCode != "x" {
+		t.Errorf("array form: records = %+v, err = %v", records, err)
+	}
+}
+
+func TestParseKutt(t *testing.T) {
+	body := `{"limit": 10, "skip": 0, "total": 2, "data": [
+		{"address": "docs", "target": "https://example.com/docs", "description": "Docs", "visit_count": 12, "created_at": "2024-02-01T12:00:00.000Z"},
+		{"link": "https://kutt.it/Ab3", "target": "https://example.com/b", "visit_count": 0}
+	]}`
+
+	records, err := ParseKutt(strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if r := records[0]; r.ShortCode != "docs" || r.Title != "Docs" || r.Clicks != 12 || r.CreatedAt == nil {
+		t.Errorf("record 0 = %+v", r)
+	}
+	if records[1].ShortCode != "Ab3" {
+		t.Errorf("record 1 code = %q, want Ab3", records[1].ShortCode)
+	}
+}
+
+func TestParseUnknownSource(t *testing.T) {
+	if _, err := Parse("tinyurl", strings.NewReader("")); err == nil {
+		t.Error("expected error for unknown source")
+	}
+}
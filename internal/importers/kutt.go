@@ -0,0 +1,51 @@
+package importers
+
+import (
+	"fmt"
+	"io"
+)
+
+type kuttLink struct {
+	Address     string  `json:"address"`
+	Link        string  `json:"link"`
+	Target      string  `json:"target"`
+	Description string  `json:"description"`
+	VisitCount  flexInt `json:"visit_count"`
+	CreatedAt   string  `json:"created_at"`
+}
+
+// ParseKutt reads Kutt links JSON: the v2 API list response ({"data": [...]})
+// or a bare array of links.
+func ParseKutt(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []kuttLink
+	if err := decodeJSONList(data, "data", &links); err != nil {
+		return nil, fmt.Errorf("kutt: %w", err)
+	}
+
+	var records []Record
+	for _, l := range links {
+		if l.Target == "" {
+			continue
+		}
+		code := l.Address
+		if code == "" {
+			code = lastSegment(l.Link)
+		}
+		records = append(records, Record{
+			ShortCode: code,
+			URL:       l.Target,
+			Title:     l.Description,
+			CreatedAt: parseTime(l.CreatedAt),
+			Clicks:    int(l.VisitCount),
+		})
+	}
+	if len(records) == 0 {
+		return nil, errEmpty
+	}
+	return records, nil
+}
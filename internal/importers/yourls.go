@@ -0,0 +1,319 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// yourlsColumns is the column order of the yourls_url table, used when an
+// INSERT statement doesn't name its columns.
+var yourlsColumns = []string{"keyword", "url", "title", "timestamp", "ip", "clicks"}
+
+type yourlsLink struct {
+	Keyword   string  `json:"keyword"`
+	ShortURL  string  `json:"shorturl"`
+	URL       string  `json:"url"`
+	Title     string  `json:"title"`
+	Timestamp string  `json:"timestamp"`
+	Clicks    flexInt `json:"clicks"`
+}
+
+// ParseYOURLS reads either a SQL dump of the yourls_url table or JSON: the
+// output of the stats API ({"links": {"link_1": {...}}}) or a plain array.
+func ParseYOURLS(r io.Reader) ([]Record, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var links []yourlsLink
+	switch trimmed := bytes.TrimSpace(data); {
+	case len(trimmed) == 0:
+		return nil, errEmpty
+	case trimmed[0] == '[':
+		if err := json.Unmarshal(trimmed, &links); err != nil {
+			return nil, fmt.Errorf("yourls: %w", err)
+		}
+	case trimmed[0] == '{':
+		var stats struct {
+			Links map[string]yourlsLink `json:"links"`
+		}
+		if err := json.Unmarshal(trimmed, &stats); err != nil {
+			return nil, fmt.Errorf("yourls: %w", err)
+		}
+		for _, l := range stats.Links {
+			links = append(links, l)
+		}
+	default:
+		if links, err = parseYOURLSDump(string(data)); err != nil {
+			return nil, fmt.Errorf("yourls: %w", err)
+		}
+	}
+
+	var records []Record
+	for _, l := range links {
+		code := l.Keyword
+		if code == "" {
+			code = lastSegment(l.ShortURL)
+		}
+		if l.URL == "" {
+			continue
+		}
+		records = append(records, Record{
+			ShortCode: code,
+			URL:       l.URL,
+			Title:     l.Title,
+			CreatedAt: parseTime(l.Timestamp),
+			Clicks:    int(l.Clicks),
+		})
+	}
+	if len(records) == 0 {
+		return nil, errEmpty
+	}
+	sortRecords(records)
+	return records, nil
+}
+
+// parseYOURLSDump extracts the rows of every INSERT INTO a *_url table in a
+// mysqldump-style file.
+func parseYOURLSDump(dump string) ([]yourlsLink, error) {
+	var links []yourlsLink
+	p := &sqlScanner{src: dump}
+
+	for p.seekInsert() {
+		table := p.identifier()
+		if !strings.HasSuffix(strings.ToLower(table), "url") {
+			continue
+		}
+
+		columns := yourlsColumns
+		p.skipSpace()
+		if p.peek() == '(' {
+			p.pos++
+			columns = nil
+			for {
+				p.skipSpace()
+				columns = append(columns, strings.ToLower(p.identifier()))
+				p.skipSpace()
+				if p.peek() != ',' {
+					break
+				}
+				p.pos++
+			}
+			if !p.expect(')') {
+				return nil, errors.New("malformed column list")
+			}
+		}
+		if !p.keyword("VALUES") {
+			return nil, errors.New("expected VALUES")
+		}
+
+		for {
+			p.skipSpace()
+			values, err := p.tuple()
+			if err != nil {
+				return nil, err
+			}
+			links = append(links, yourlsRow(columns, values))
+			p.skipSpace()
+			if p.peek() != ',' {
+				break
+			}
+			p.pos++
+		}
+	}
+
+	if len(links) == 0 {
+		return nil, errEmpty
+	}
+	return links, nil
+}
+
+func yourlsRow(columns, values []string) yourlsLink {
+	var l yourlsLink
+	for i, col := range columns {
+		if i >= len(values) {
+			break
+		}
+		switch col {
+		case "keyword":
+			l.Keyword = values[i]
+		case "url":
+			l.URL = values[i]
+		case "title":
+			l.Title = values[i]
+		case "timestamp":
+			l.Timestamp = values[i]
+		case "clicks":
+			n, _ := strconv.Atoi(values[i])
+			l.Clicks = flexInt(n)
+		}
+	}
+	return l
+}
+
+// sqlScanner is just enough of a MySQL tokenizer to read INSERT statements.
+type sqlScanner struct {
+	src string
+	pos int
+}
+
+func (p *sqlScanner) peek() byte {
+	if p.pos < len(p.src) {
+		return p.src[p.pos]
+	}
+	return 0
+}
+
+func (p *sqlScanner) skipSpace() {
+	for p.pos < len(p.src) && strings.IndexByte(" \t\r\n", p.src[p.pos]) >= 0 {
+		p.pos++
+	}
+}
+
+func (p *sqlScanner) expect(c byte) bool {
+	p.skipSpace()
+	if p.peek() != c {
+		return false
+	}
+	p.pos++
+	return true
+}
+
+func (p *sqlScanner) keyword(kw string) bool {
+	p.skipSpace()
+	if len(p.src)-p.pos < len(kw) || !strings.EqualFold(p.src[p.pos:p.pos+len(kw)], kw) {
+		return false
+	}
+	p.pos += len(kw)
+	return true
+}
+
+// seekInsert moves past the next "INSERT INTO" (or "INSERT IGNORE INTO").
+func (p *sqlScanner) seekInsert() bool {
+	const kw = "INSERT "
+	for ; p.pos+len(kw) <= len(p.src); p.pos++ {
+		if !strings.EqualFold(p.src[p.pos:p.pos+len(kw)], kw) {
+			continue
+		}
+		p.pos += len(kw)
+		p.keyword("IGNORE")
+		if p.keyword("INTO") {
+			return true
+		}
+		p.pos--
+	}
+	return false
+}
+
+// identifier reads a bare or backtick-quoted name, dropping any schema prefix.
+func (p *sqlScanner) identifier() string {
+	p.skipSpace()
+	var name string
+	for {
+		if p.peek() == '`' {
+			end := strings.IndexByte(p.src[p.pos+1:], '`')
+			if end < 0 {
+				p.pos = len(p.src)
+				return name
+			}
+			name = p.src[p.pos+1 : p.pos+1+end]
+			p.pos += end + 2
+		} else {
+			start := p.pos
+			for p.pos < len(p.src) && (isIdentChar(p.src[p.pos])) {
+				p.pos++
+			}
+			name = p.src[start:p.pos]
+		}
+		if p.peek() != '.' {
+			return name
+		}
+		p.pos++
+	}
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// tuple reads "(v1, v2, ...)" with quoted strings, numbers and NULL.
+func (p *sqlScanner) tuple() ([]string, error) {
+	if !p.expect('(') {
+		return nil, errors.New("expected value tuple")
+	}
+	var values []string
+	for {
+		p.skipSpace()
+		v, err := p.value()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, v)
+		p.skipSpace()
+		switch p.peek() {
+		case ',':
+			p.pos++
+		case ')':
+			p.pos++
+			return values, nil
+		default:
+			return nil, errors.New("malformed value tuple")
+		}
+	}
+}
+
+func (p *sqlScanner) value() (string, error) {
+	if q := p.peek(); q == '\'' || q == '"' {
+		p.pos++
+		var sb strings.Builder
+		for p.pos < len(p.src) {
+			c := p.src[p.pos]
+			switch {
+			case c == '\\' && p.pos+1 < len(p.src):
+				p.pos++
+				sb.WriteByte(unescapeSQL(p.src[p.pos]))
+			case c == q && p.pos+1 < len(p.src) && p.src[p.pos+1] == q:
+				p.pos++
+				sb.WriteByte(q)
+			case c == q:
+				p.pos++
+				return sb.String(), nil
+			default:
+				sb.WriteByte(c)
+			}
+			p.pos++
+		}
+		return "", errors.New("unterminated string")
+	}
+
+	start := p.pos
+	for p.pos < len(p.src) && p.src[p.pos] != ',' && p.src[p.pos] != ')' {
+		p.pos++
+	}
+	v := strings.TrimSpace(p.src[start:p.pos])
+	if strings.EqualFold(v, "NULL") {
+		return "", nil
+	}
+	return v, nil
+}
+
+func unescapeSQL(c byte) byte {
+	switch c {
+	case 'n':
+		return '\n'
+	case 'r':
+		return '\r'
+	case 't':
+		return '\t'
+	case '0':
+		return 0
+	default:
+		return c
+	}
+}
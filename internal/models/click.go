@@ -3,28 +3,116 @@ package models
 import "time"
 
 type Click struct {
-	ID        int       `json:"id"`
-	LinkID    int       `json:"link_id"`
-	IPAddress string    `json:"ip_address,omitempty"`
-	UserAgent string    `json:"user_agent,omitempty"`
-	Referer   string    `json:"referer,omitempty"`
-	Country   string    `json:"country,omitempty"`
-	City      string    `json:"city,omitempty"`
-	Device    string    `json:"device,omitempty"`
-	Browser   string    `json:"browser,omitempty"`
-	OS        string    `json:"os,omitempty"`
-	CreatedAt time.Time `json:"created_at"`
+	ID             int       `json:"id"`
+	LinkID         int       `json:"link_id"`
+	Revision       int       `json:"revision,omitempty"`
+	IPAddress      string    `json:"ip_address,omitempty"`
+	UserAgent      string    `json:"user_agent,omitempty"`
+	Referer        string    `json:"referer,omitempty"`
+	Country        string    `json:"country,omitempty"`
+	City           string    `json:"city,omitempty"`
+	Device         string    `json:"device,omitempty"`
+	DeviceBrand    string    `json:"device_brand,omitempty"`
+	DeviceModel    string    `json:"device_model,omitempty"`
+	Browser        string    `json:"browser,omitempty"`
+	BrowserVersion string    `json:"browser_version,omitempty"`
+	OS             string    `json:"os,omitempty"`
+	OSVersion      string    `json:"os_version,omitempty"`
+	Engine         string    `json:"engine,omitempty"`
+	IsBot          bool      `json:"is_bot,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type ClickListResponse struct {
+	Clicks     []Click `json:"clicks"`
+	NextCursor string  `json:"next_cursor,omitempty"`
+	PrevCursor string  `json:"prev_cursor,omitempty"`
+}
+
+// StatsQuery is the window and bucketing of a stats request. Totals and
+// breakdowns cover [From, To) when Bounded, all time otherwise; the series
+// always covers [From, To) in Interval buckets of local time in Location.
+// With Compare, the same figures are also computed for a window of the
+// same length starting at CompareFrom, or the one right before if zero.
+// Bot clicks are left out unless IncludeBots.
+type StatsQuery struct {
+	From        time.Time
+	To          time.Time
+	Bounded     bool
+	Location    *time.Location
+	Interval    string // hour, day, week, month
+	Compare     bool
+	CompareFrom time.Time
+	IncludeBots bool
 }
 
 type ClickStats struct {
-	TotalClicks    int               `json:"total_clicks"`
-	UniqueClicks   int               `json:"unique_clicks"`
-	ClicksByDay    []DayCount        `json:"clicks_by_day"`
-	TopReferrers   []NameCount       `json:"top_referrers"`
-	TopCountries   []NameCount       `json:"top_countries"`
-	TopBrowsers    []NameCount       `json:"top_browsers"`
-	TopDevices     []NameCount       `json:"top_devices"`
-	TopOS          []NameCount       `json:"top_os"`
+	From           time.Time        `json:"from"`
+	To             time.Time        `json:"to"`
+	Timezone       string           `json:"timezone"`
+	Interval       string           `json:"interval"`
+	IncludeBots    bool             `json:"include_bots"`
+	TotalClicks    int              `json:"total_clicks"`
+	UniqueClicks   int              `json:"unique_clicks"`
+	ImportedClicks int              `json:"imported_clicks,omitempty"` // included in total_clicks, all-time stats only
+	Series         []TimeCount      `json:"series"`
+	ClicksByDay    []DayCount       `json:"clicks_by_day,omitempty"` // series by date, interval=day only
+	TopReferrers   []NameCount      `json:"top_referrers"`
+	TopCountries   []NameCount      `json:"top_countries"`
+	TopBrowsers    []NameCount      `json:"top_browsers"`
+	TopDevices     []NameCount      `json:"top_devices"`
+	TopOS          []NameCount      `json:"top_os"`
+	Comparison     *StatsComparison `json:"comparison,omitempty"`
+}
+
+// AggregateStats is ClickStats over a set of links, with the busiest ones.
+type AggregateStats struct {
+	ClickStats
+	Links    int         `json:"links"`
+	TopLinks []LinkCount `json:"top_links"`
+}
+
+type LinkCount struct {
+	ID        int    `json:"id"`
+	ShortCode string `json:"short_code"`
+	ShortURL  string `json:"short_url"`
+	Title     string `json:"title,omitempty"`
+	Clicks    int    `json:"clicks"`
+}
+
+// StatsComparison sets a stats window against an earlier one. The Top*
+// lists keep the current window's top values, each with its earlier count.
+type StatsComparison struct {
+	From         time.Time    `json:"from"`
+	To           time.Time    `json:"to"`
+	TotalClicks  Change       `json:"total_clicks"`
+	UniqueClicks Change       `json:"unique_clicks"`
+	Series       []TimeCount  `json:"series"`
+	TopReferrers []NameChange `json:"top_referrers"`
+	TopCountries []NameChange `json:"top_countries"`
+	TopBrowsers  []NameChange `json:"top_browsers"`
+	TopDevices   []NameChange `json:"top_devices"`
+	TopOS        []NameChange `json:"top_os"`
+}
+
+// Change is a figure in both windows. PctChange is nil when there is
+// nothing to compare against.
+type Change struct {
+	Current   int      `json:"current"`
+	Previous  int      `json:"previous"`
+	Delta     int      `json:"delta"`
+	PctChange *float64 `json:"pct_change"`
+}
+
+type NameChange struct {
+	Name string `json:"name"`
+	Change
+}
+
+// TimeCount is one series bucket; Time is its start in the requested zone.
+type TimeCount struct {
+	Time  time.Time `json:"time"`
+	Count int       `json:"count"`
 }
 
 type DayCount struct {
@@ -12,38 +12,162 @@ type Link struct {
 	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
 	MaxClicks    *int       `json:"max_clicks,omitempty"`
 	PasswordHash string     `json:"-"`
+	HasPassword  bool       `json:"has_password"`
+	SingleUse    bool       `json:"single_use"`
+	ConsumedAt   *time.Time `json:"consumed_at,omitempty"`
+	Revision     int        `json:"revision"`
 	CreatedAt    time.Time  `json:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at"`
+	DeletedAt    *time.Time `json:"deleted_at,omitempty"`
 	ClickCount   int        `json:"click_count,omitempty"`
 	ShortURL     string     `json:"short_url,omitempty"`
 	Tags         []Tag      `json:"tags,omitempty"`
 }
 
 type CreateLinkRequest struct {
+	URL        string     `json:"url"`
+	Title      string     `json:"title,omitempty"`
+	CustomCode string     `json:"custom_code,omitempty"`
+	ExpiresIn  int        `json:"expires_in,omitempty"` // days
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"` // takes precedence over expires_in
+	MaxClicks  *int       `json:"max_clicks,omitempty"`
+	Password   string     `json:"password,omitempty"`
+	SingleUse  bool       `json:"single_use,omitempty"` // works for exactly one visit
+	Tags       []string   `json:"tags,omitempty"`
+}
+
+// LinkRecord is the flat form of a link used by export and import.
+type LinkRecord struct {
+	ShortCode  string     `json:"short_code"`
+	URL        string     `json:"url"`
+	Title      string     `json:"title,omitempty"`
+	Tags       []string   `json:"tags,omitempty"`
+	IsActive   bool       `json:"is_active"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	MaxClicks  *int       `json:"max_clicks,omitempty"`
+	ClickCount int        `json:"click_count"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// ExternalImportReport summarizes an import from another shortener.
+// Conflicts are short codes already in use here; they are skipped.
+type ExternalImportReport struct {
+	Source    string        `json:"source"`
+	Total     int           `json:"total"`
+	Imported  int           `json:"imported"`
+	Conflicts []ImportIssue `json:"conflicts,omitempty"`
+	Errors    []ImportIssue `json:"errors,omitempty"`
+}
+
+type ImportIssue struct {
+	ShortCode string `json:"short_code"`
 	URL       string `json:"url"`
-	Title     string `json:"title,omitempty"`
-	CustomCode string `json:"custom_code,omitempty"`
-	ExpiresIn  int    `json:"expires_in,omitempty"` // days
-	MaxClicks  *int   `json:"max_clicks,omitempty"`
-	Password   string `json:"password,omitempty"`
-	Tags       []string `json:"tags,omitempty"`
+	Error     string `json:"error"`
 }
 
+// UpdateLinkRequest is a partial update: nil fields are left untouched.
+// An empty expires_at or password clears it, max_clicks 0 removes the limit
+// and a non-nil tags list replaces the link's tags.
 type UpdateLinkRequest struct {
-	Title     *string `json:"title,omitempty"`
-	IsActive  *bool   `json:"is_active,omitempty"`
-	ExpiresAt *string `json:"expires_at,omitempty"`
-	MaxClicks *int    `json:"max_clicks,omitempty"`
+	URL       *string  `json:"url,omitempty"`
+	Title     *string  `json:"title,omitempty"`
+	IsActive  *bool    `json:"is_active,omitempty"`
+	ExpiresAt *string  `json:"expires_at,omitempty"`
+	MaxClicks *int     `json:"max_clicks,omitempty"`
+	Password  *string  `json:"password,omitempty"`
+	Tags      []string `json:"tags,omitempty"`
+}
+
+// LinkRevision is a snapshot of a link's destination and settings, written
+// every time the link changes.
+type LinkRevision struct {
+	Revision    int        `json:"revision"`
+	OriginalURL string     `json:"original_url"`
+	Title       string     `json:"title,omitempty"`
+	IsActive    bool       `json:"is_active"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	MaxClicks   *int       `json:"max_clicks,omitempty"`
+	HasPassword bool       `json:"has_password"`
+	ChangedBy   *int       `json:"changed_by,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// BatchRequest applies one action to many links, picked by id, by filter,
+// or both. Actions: delete, activate, deactivate, add_tag, remove_tag,
+// set_expiry (expires_at, empty clears) and set_max_clicks (0 clears).
+type BatchRequest struct {
+	IDs       []int       `json:"ids,omitempty"`
+	Filter    *LinkFilter `json:"filter,omitempty"`
+	Action    string      `json:"action"`
+	Tag       string      `json:"tag,omitempty"`
+	ExpiresAt string      `json:"expires_at,omitempty"`
+	MaxClicks int         `json:"max_clicks,omitempty"`
+}
+
+type BatchResult struct {
+	ID    int    `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type BatchResponse struct {
+	Action   string        `json:"action"`
+	Affected int           `json:"affected"`
+	Results  []BatchResult `json:"results"`
 }
 
+// LinkFilter narrows and orders a user's link listing. Zero values mean
+// "no filter"; Sort defaults to created_at and Order to desc.
+type LinkFilter struct {
+	Query         string     `json:"q,omitempty"`
+	Tag           string     `json:"tag,omitempty"`
+	Status        string     `json:"status,omitempty"` // active, inactive, expired
+	CreatedAfter  *time.Time `json:"created_from,omitempty"`
+	CreatedBefore *time.Time `json:"created_to,omitempty"`
+	Domain        string     `json:"domain,omitempty"`
+	Sort          string     `json:"sort,omitempty"`  // created_at, clicks, title
+	Order         string     `json:"order,omitempty"` // asc, desc
+	Trashed       bool       `json:"-"`               // list the trash instead of live links
+}
+
+// SecurityEvent is a suspicious access to a link, e.g. a failed unlock.
+type SecurityEvent struct {
+	ID        int       `json:"id"`
+	EventType string    `json:"event_type"`
+	IPAddress string    `json:"ip_address,omitempty"`
+	UserAgent string    `json:"user_agent,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// LinkListResponse carries either page/total (offset mode) or next/prev
+// cursors (cursor mode).
 type LinkListResponse struct {
 	Links      []Link `json:"links"`
 	Total      int    `json:"total"`
 	Page       int    `json:"page"`
 	PerPage    int    `json:"per_page"`
+	NextCursor string `json:"next_cursor,omitempty"`
+	PrevCursor string `json:"prev_cursor,omitempty"`
 }
 
 type Tag struct {
 	ID   int    `json:"id"`
 	Name string `json:"name"`
 }
+
+type TagSummary struct {
+	Tag
+	LinkCount int `json:"link_count"`
+}
+
+type TagRequest struct {
+	Name string `json:"name"`
+}
+
+type MergeTagRequest struct {
+	Into int `json:"into"`
+}
+
+type LinkTagsRequest struct {
+	Tags []string `json:"tags"`
+}
@@ -0,0 +1,204 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shortly/internal/models"
+)
+
+// maxBatchLinks caps how many links one batch request may touch.
+const maxBatchLinks = 5000
+
+// Batch applies one action to many of the user's links in a single
+// statement and evicts their cached redirects in one pipeline. Links that
+// don't exist, aren't the user's or are in the trash are reported as not found.
+func (s *LinkService) Batch(ctx context.Context, userID int, req models.BatchRequest) (*models.BatchResponse, error) {
+	if len(req.IDs) == 0 && req.Filter == nil {
+		return nil, errors.New("ids or filter is required")
+	}
+
+	ids, err := s.batchTargets(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > maxBatchLinks {
+		return nil, fmt.Errorf("max %d links per batch", maxBatchLinks)
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := s.batchExec(ctx, tx, userID, ids, req)
+	if err != nil {
+		return nil, err
+	}
+	affected := map[int]string{}
+	for rows.Next() {
+		var id int
+		var code string
+		if err := rows.Scan(&id, &code); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		affected[id] = code
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("batch %s: %w", req.Action, err)
+	}
+
+	changed := make([]int, 0, len(affected))
+	codes := make([]string, 0, len(affected))
+	for id, code := range affected {
+		changed = append(changed, id)
+		codes = append(codes, code)
+	}
+
+	switch req.Action {
+	case "activate", "deactivate", "set_expiry", "set_max_clicks":
+		if err := s.recordRevisions(ctx, tx, changed, userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	s.evictLinks(ctx, codes...)
+
+	resp := &models.BatchResponse{Action: req.Action, Affected: len(affected), Results: make([]models.BatchResult, 0, len(ids))}
+	for _, id := range ids {
+		if _, ok := affected[id]; ok {
+			resp.Results = append(resp.Results, models.BatchResult{ID: id, OK: true})
+		} else {
+			resp.Results = append(resp.Results, models.BatchResult{ID: id, Error: ErrNotFound.Error()})
+		}
+	}
+	return resp, nil
+}
+
+// batchTargets merges the explicit ids with the ids matched by the filter,
+// keeping the order they were given in.
+func (s *LinkService) batchTargets(ctx context.Context, userID int, req models.BatchRequest) ([]int, error) {
+	seen := map[int]bool{}
+	var ids []int
+	for _, id := range req.IDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	if req.Filter == nil {
+		return ids, nil
+	}
+
+	where, args := buildLinkFilter(userID, *req.Filter)
+	args = append(args, maxBatchLinks+1)
+	rows, err := s.db.Query(ctx,
+		fmt.Sprintf("SELECT l.id FROM links l WHERE %s ORDER BY l.id LIMIT $%d", where, len(args)),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids, rows.Err()
+}
+
+// batchExec runs the action and returns (id, short_code) of every link it
+// applied to.
+func (s *LinkService) batchExec(ctx context.Context, tx pgx.Tx, userID int, ids []int, req models.BatchRequest) (pgx.Rows, error) {
+	const owned = "id = ANY($1) AND user_id=$2 AND deleted_at IS NULL"
+	const touch = "revision=revision+1, updated_at=NOW()"
+
+	switch req.Action {
+	case "delete":
+		return tx.Query(ctx,
+			`UPDATE links SET deleted_at=NOW() WHERE `+owned+` RETURNING id, short_code`, ids, userID)
+
+	case "activate", "deactivate":
+		return tx.Query(ctx,
+			`UPDATE links SET is_active=$3, `+touch+` WHERE `+owned+` RETURNING id, short_code`,
+			ids, userID, req.Action == "activate")
+
+	case "set_expiry":
+		var expiresAt *time.Time
+		if req.ExpiresAt != "" {
+			t, err := time.Parse(time.RFC3339, req.ExpiresAt)
+			if err != nil {
+				return nil, errors.New("invalid expires_at: use RFC 3339")
+			}
+			expiresAt = &t
+		}
+		return tx.Query(ctx,
+			`UPDATE links SET expires_at=$3, `+touch+` WHERE `+owned+` RETURNING id, short_code`,
+			ids, userID, expiresAt)
+
+	case "set_max_clicks":
+		if req.MaxClicks < 0 {
+			return nil, errors.New("max_clicks must not be negative")
+		}
+		var maxClicks *int
+		if req.MaxClicks > 0 {
+			maxClicks = &req.MaxClicks
+		}
+		return tx.Query(ctx,
+			`UPDATE links SET max_clicks=$3, `+touch+` WHERE `+owned+` RETURNING id, short_code`,
+			ids, userID, maxClicks)
+
+	case "add_tag":
+		name, err := normalizeTagName(req.Tag)
+		if err != nil {
+			return nil, err
+		}
+		var tagID int
+		err = tx.QueryRow(ctx,
+			`INSERT INTO tags (name, user_id) VALUES ($1, $2) ON CONFLICT (name, user_id) DO UPDATE SET name=$1 RETURNING id`,
+			name, userID,
+		).Scan(&tagID)
+		if err != nil {
+			return nil, err
+		}
+		return tx.Query(ctx,
+			`WITH targets AS (SELECT id, short_code FROM links WHERE `+owned+`),
+			 tagged AS (INSERT INTO link_tags (link_id, tag_id) SELECT id, $3 FROM targets ON CONFLICT DO NOTHING)
+			 SELECT id, short_code FROM targets`,
+			ids, userID, tagID)
+
+	case "remove_tag":
+		name, err := normalizeTagName(req.Tag)
+		if err != nil {
+			return nil, err
+		}
+		return tx.Query(ctx,
+			`WITH targets AS (SELECT id, short_code FROM links WHERE `+owned+`),
+			 untagged AS (
+			     DELETE FROM link_tags WHERE link_id IN (SELECT id FROM targets)
+			     AND tag_id IN (SELECT id FROM tags WHERE name=$3 AND user_id=$2)
+			 )
+			 SELECT id, short_code FROM targets`,
+			ids, userID, name)
+
+	default:
+		return nil, errors.New("action must be one of delete, activate, deactivate, add_tag, remove_tag, set_expiry, set_max_clicks")
+	}
+}
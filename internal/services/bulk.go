@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shortly/internal/models"
+	"github.com/shortly/internal/utils"
+)
+
+var ErrBatchRejected = errors.New("batch rejected: another item failed")
+
+// bulkMaxPasswords caps the password-protected items of one bulk create.
+// Each costs a bcrypt hash, around 50ms of CPU.
+const bulkMaxPasswords = 50
+
+// bulkItem is one validated entry of a bulk create.
+type bulkItem struct {
+	index        int
+	req          models.CreateLinkRequest
+	code         string
+	expiresAt    *time.Time
+	passwordHash *string
+}
+
+// BulkCreate creates many links with a handful of round trips: custom codes
+// and generated codes are checked for collisions in one query each, and the
+// inserts go out as a single pgx batch inside one transaction.
+//
+// The returned slices are aligned with reqs: links[i] is set on success,
+// errs[i] otherwise. In atomic mode nothing is written unless every item
+// succeeds, and items that were fine on their own get ErrBatchRejected.
+func (s *LinkService) BulkCreate(ctx context.Context, userID int, reqs []models.CreateLinkRequest, atomic bool) ([]*models.Link, []error, error) {
+	if len(reqs) > s.cfg.BulkMaxURLs {
+		return nil, nil, fmt.Errorf("max %d urls per batch", s.cfg.BulkMaxURLs)
+	}
+	withPassword := 0
+	for _, req := range reqs {
+		if req.Password != "" {
+			withPassword++
+		}
+	}
+	if withPassword > bulkMaxPasswords {
+		return nil, nil, fmt.Errorf("max %d password-protected urls per batch", bulkMaxPasswords)
+	}
+
+	links := make([]*models.Link, len(reqs))
+	errs := make([]error, len(reqs))
+
+	items := make([]*bulkItem, 0, len(reqs))
+	custom := map[string]int{}
+	for i, req := range reqs {
+		// hashing takes a while; stop if the client has gone
+		if err := ctx.Err(); err != nil {
+			return nil, nil, err
+		}
+		item, err := validateBulkItem(i, req)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		if item.code != "" {
+			if _, dup := custom[item.code]; dup {
+				errs[i] = errors.New("custom code repeated in batch")
+				continue
+			}
+			custom[item.code] = i
+		}
+		items = append(items, item)
+	}
+
+	// custom code collisions, one query for the whole batch
+	if len(custom) > 0 {
+		codes := make([]string, 0, len(custom))
+		for c := range custom {
+			codes = append(codes, c)
+		}
+		taken, err := s.existingCodes(ctx, codes)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, c := range taken {
+			errs[custom[c]] = ErrCodeTaken
+		}
+	}
+
+	if err := s.assignCodes(ctx, items, custom); err != nil {
+		return nil, nil, err
+	}
+
+	pending := items[:0]
+	for _, item := range items {
+		if errs[item.index] == nil {
+			pending = append(pending, item)
+		}
+	}
+	if atomic && len(pending) < len(reqs) {
+		return links, rejectRest(errs), nil
+	}
+	if len(pending) == 0 {
+		return links, errs, nil
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	batch := &pgx.Batch{}
+	for _, item := range pending {
+		batch.Queue(
+			`INSERT INTO links (short_code, original_url, title, user_id, expires_at, max_clicks, password_hash, single_use)
+			 VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			 ON CONFLICT (short_code) DO NOTHING
+			 RETURNING id, short_code, original_url, title, user_id, is_active, expires_at, max_clicks, single_use, revision, created_at, updated_at`,
+			item.code, item.req.URL, item.req.Title, userID, item.expiresAt, item.req.MaxClicks, item.passwordHash, item.req.SingleUse,
+		)
+	}
+
+	br := tx.SendBatch(ctx, batch)
+	var ids []int
+	for _, item := range pending {
+		link := &models.Link{}
+		err := br.QueryRow().Scan(&link.ID, &link.ShortCode, &link.OriginalURL, &link.Title, &link.UserID,
+			&link.IsActive, &link.ExpiresAt, &link.MaxClicks, &link.SingleUse, &link.Revision, &link.CreatedAt, &link.UpdatedAt)
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				// lost a race for the code since the pre-check
+				errs[item.index] = ErrCodeTaken
+				continue
+			}
+			br.Close()
+			return nil, nil, fmt.Errorf("insert link: %w", err)
+		}
+		link.ShortURL = fmt.Sprintf("%s/%s", s.cfg.BaseURL, link.ShortCode)
+		link.HasPassword = item.passwordHash != nil
+		links[item.index] = link
+		ids = append(ids, link.ID)
+	}
+	if err := br.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	if atomic && len(ids) < len(reqs) {
+		for i := range links {
+			links[i] = nil
+		}
+		return links, rejectRest(errs), nil
+	}
+
+	if len(ids) > 0 {
+		if err := s.recordRevisions(ctx, tx, ids, userID); err != nil {
+			return nil, nil, err
+		}
+		if err := s.bulkAttachTags(ctx, tx, userID, pending, links); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	created := make([]models.Link, 0, len(ids))
+	codes := make([]string, 0, len(ids))
+	var pos []int
+	for i, l := range links {
+		if l != nil {
+			created = append(created, *l)
+			codes = append(codes, l.ShortCode)
+			pos = append(pos, i)
+		}
+	}
+	// clear any cached "not found" for the new codes
+	s.evictLinks(ctx, codes...)
+	if err := s.loadTags(ctx, created); err == nil {
+		for j, l := range created {
+			links[pos[j]].Tags = l.Tags
+		}
+	}
+	return links, errs, nil
+}
+
+func validateBulkItem(i int, req models.CreateLinkRequest) (*bulkItem, error) {
+	if !utils.IsValidURL(req.URL) {
+		return nil, errors.New("invalid url")
+	}
+	if len(req.Title) > 200 {
+		return nil, errors.New("title must be at most 200 chars")
+	}
+	item := &bulkItem{index: i, req: req}
+	if req.CustomCode != "" {
+		if !utils.IsValidCustomCode(req.CustomCode) {
+			return nil, errors.New("invalid custom code: 3-20 alphanumeric chars, hyphens, underscores, not a reserved route")
+		}
+		item.code = req.CustomCode
+	}
+	item.expiresAt = req.ExpiresAt
+	if item.expiresAt == nil && req.ExpiresIn > 0 {
+		t := time.Now().Add(time.Duration(req.ExpiresIn) * 24 * time.Hour)
+		item.expiresAt = &t
+	}
+	if req.Password != "" {
+		h, err := HashLinkPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		item.passwordHash = &h
+	}
+	return item, nil
+}
+
+// assignCodes generates codes for items without a custom one, re-rolling
+// the ones that collide with existing links or with each other.
+func (s *LinkService) assignCodes(ctx context.Context, items []*bulkItem, custom map[string]int) error {
+	var todo []*bulkItem
+	for _, item := range items {
+		if item.code == "" {
+			todo = append(todo, item)
+		}
+	}
+
+	used := make(map[string]bool, len(custom))
+	for c := range custom {
+		used[c] = true
+	}
+
+	for attempt := 0; len(todo) > 0; attempt++ {
+		if attempt == 5 {
+			return errors.New("could not generate unique codes")
+		}
+		codes := make([]string, 0, len(todo))
+		for _, item := range todo {
+			for {
+				code, err := utils.GenerateShortCode(s.cfg.ShortCodeLength)
+				if err != nil {
+					return err
+				}
+				if !used[code] {
+					used[code] = true
+					item.code = code
+					codes = append(codes, code)
+					break
+				}
+			}
+		}
+
+		taken, err := s.existingCodes(ctx, codes)
+		if err != nil {
+			return err
+		}
+		collided := make(map[string]bool, len(taken))
+		for _, c := range taken {
+			collided[c] = true
+		}
+		retry := todo[:0]
+		for _, item := range todo {
+			if collided[item.code] {
+				retry = append(retry, item)
+			}
+		}
+		todo = retry
+	}
+	return nil
+}
+
+// existingCodes returns which of codes are already in use.
+func (s *LinkService) existingCodes(ctx context.Context, codes []string) ([]string, error) {
+	rows, err := s.db.Query(ctx, "SELECT short_code FROM links WHERE short_code = ANY($1)", codes)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var taken []string
+	for rows.Next() {
+		var c string
+		if err := rows.Scan(&c); err != nil {
+			return nil, err
+		}
+		taken = append(taken, c)
+	}
+	return taken, rows.Err()
+}
+
+// bulkAttachTags upserts every tag named in the batch with one statement and
+// links them with another.
+func (s *LinkService) bulkAttachTags(ctx context.Context, q querier, userID int, items []*bulkItem, links []*models.Link) error {
+	var linkIDs []int
+	var names []string
+	for _, item := range items {
+		link := links[item.index]
+		if link == nil {
+			continue
+		}
+		for _, name := range item.req.Tags {
+			name, err := normalizeTagName(name)
+			if err != nil {
+				continue
+			}
+			linkIDs = append(linkIDs, link.ID)
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	_, err := q.Exec(ctx,
+		`WITH pairs AS (
+		     SELECT * FROM unnest($1::int[], $2::text[]) AS p(link_id, name)
+		 ), upserted AS (
+		     INSERT INTO tags (name, user_id) SELECT DISTINCT name, $3 FROM pairs
+		     ON CONFLICT (name, user_id) DO UPDATE SET name=EXCLUDED.name
+		     RETURNING id, name
+		 )
+		 INSERT INTO link_tags (link_id, tag_id)
+		 SELECT p.link_id, u.id FROM pairs p JOIN upserted u ON u.name = p.name
+		 ON CONFLICT DO NOTHING`,
+		linkIDs, names, userID,
+	)
+	if err != nil {
+		return fmt.Errorf("attach tags: %w", err)
+	}
+	return nil
+}
+
+// rejectRest marks every item without its own error as rejected.
+func rejectRest(errs []error) []error {
+	for i, err := range errs {
+		if err == nil {
+			errs[i] = ErrBatchRejected
+		}
+	}
+	return errs
+}
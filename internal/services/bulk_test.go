@@ -0,0 +1,33 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/shortly/internal/config"
+	"github.com/shortly/internal/models"
+)
+
+func TestBulkCreateCapsPasswords(t *testing.T) {
+	s := &LinkService{cfg: &config.Config{BulkMaxURLs: 1000}}
+	reqs := make([]models.CreateLinkRequest, bulkMaxPasswords+1)
+	for i := range reqs {
+		reqs[i] = models.CreateLinkRequest{URL: "https://example.com", Password: "secret"}
+	}
+	// refused before any hashing or database work
+	_, _, err := s.BulkCreate(context.Background(), 1, reqs, false)
+	if err == nil || !strings.Contains(err.Error(), "password-protected") {
+		t.Errorf("BulkCreate(%d passwords) err = %v", len(reqs), err)
+	}
+}
+
+func TestBulkCreateStopsWhenCancelled(t *testing.T) {
+	s := &LinkService{cfg: &config.Config{BulkMaxURLs: 1000}}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	reqs := []models.CreateLinkRequest{{URL: "https://example.com", Password: "secret"}}
+	if _, _, err := s.BulkCreate(ctx, 1, reqs, false); err != context.Canceled {
+		t.Errorf("BulkCreate(cancelled) err = %v, want context.Canceled", err)
+	}
+}
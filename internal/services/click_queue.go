@@ -0,0 +1,415 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+
+	"github.com/shortly/internal/models"
+	"github.com/shortly/internal/utils"
+)
+
+// clickEvent is a visit waiting to be written. counted means Admit already
+// bumped the link's click_count; bot visits are never counted.
+type clickEvent struct {
+	linkID    int
+	revision  int
+	counted   bool
+	bot       bool
+	ip        string
+	userAgent string
+	referer   string
+	at        time.Time
+}
+
+// ClickQueueStats is a snapshot of the ingestion pipeline's counters.
+type ClickQueueStats struct {
+	Depth    int   `json:"depth"`
+	Capacity int   `json:"capacity"`
+	Enqueued int64 `json:"enqueued"`
+	Dropped  int64 `json:"dropped"`
+	Written  int64 `json:"written"`
+	Failed   int64 `json:"failed"`
+	Batches  int64 `json:"batches"`
+	Spooled  int64 `json:"spooled"`
+	Replayed int64 `json:"replayed"`
+	// bytes waiting in the spool file for the database to come back
+	SpoolBytes int64 `json:"spool_bytes"`
+}
+
+type clickQueue struct {
+	ch         chan clickEvent
+	batchSize  int
+	flushEvery time.Duration
+	write      func(ctx context.Context, batch []clickEvent) error
+	spill      func(batch []clickEvent) error // optional fallback when write fails
+
+	mu     sync.RWMutex // guards closed against sends on a closed channel
+	closed bool
+	wg     sync.WaitGroup
+
+	enqueued, dropped, written, failed, batches, spooled, replayed atomic.Int64
+}
+
+// minClickFlush keeps a zero or negative CLICK_FLUSH_MS from panicking the
+// ticker or spinning the workers.
+const minClickFlush = 10 * time.Millisecond
+
+func newClickQueue(size, batchSize int, flushEvery time.Duration, write func(context.Context, []clickEvent) error) *clickQueue {
+	return &clickQueue{
+		ch:         make(chan clickEvent, max(size, 1)),
+		batchSize:  max(batchSize, 1),
+		flushEvery: max(flushEvery, minClickFlush),
+		write:      write,
+	}
+}
+
+// push never blocks: a redirect is worth more than its click, so when the
+// queue is full the click is dropped and counted.
+func (q *clickQueue) push(e clickEvent) bool {
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		q.dropped.Add(1)
+		return false
+	}
+	select {
+	case q.ch <- e:
+		q.enqueued.Add(1)
+		return true
+	default:
+		q.dropped.Add(1)
+		return false
+	}
+}
+
+func (q *clickQueue) start(workers int) {
+	for i := 0; i < max(workers, 1); i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+}
+
+// work collects events into batches and writes one when it is full or when
+// flushEvery passes, whichever comes first.
+func (q *clickQueue) work() {
+	defer q.wg.Done()
+	ticker := time.NewTicker(q.flushEvery)
+	defer ticker.Stop()
+
+	batch := make([]clickEvent, 0, q.batchSize)
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		q.batches.Add(1)
+		n := int64(len(batch))
+		err := q.write(ctx, batch)
+		switch {
+		case err == nil:
+			q.written.Add(n)
+		case q.spill == nil || !isTransientError(err):
+			// the spool is for outages; rows postgres refuses would only
+			// block its replay
+			q.failed.Add(n)
+			log.Printf("write %d clicks: %v", n, err)
+		default:
+			if serr := q.spill(batch); serr != nil {
+				q.failed.Add(n)
+				log.Printf("write %d clicks: %v", n, errors.Join(err, serr))
+			} else {
+				q.spooled.Add(n)
+				log.Printf("write %d clicks: %v (spooled to disk)", n, err)
+			}
+		}
+		batch = batch[:0]
+	}
+
+	for {
+		select {
+		case e, ok := <-q.ch:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= q.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// close stops accepting clicks and waits until the workers have written
+// everything already queued, or ctx is done.
+func (q *clickQueue) close(ctx context.Context) error {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.ch)
+	}
+	q.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("click queue: %d clicks not written: %w", len(q.ch), ctx.Err())
+	}
+}
+
+func (q *clickQueue) stats() ClickQueueStats {
+	return ClickQueueStats{
+		Depth:    len(q.ch),
+		Capacity: cap(q.ch),
+		Enqueued: q.enqueued.Load(),
+		Dropped:  q.dropped.Load(),
+		Written:  q.written.Load(),
+		Failed:   q.failed.Load(),
+		Batches:  q.batches.Load(),
+		Spooled:  q.spooled.Load(),
+		Replayed: q.replayed.Load(),
+	}
+}
+
+var clickColumns = []string{"link_id", "revision", "ip_address", "user_agent", "referer",
+	"country", "city", "device", "device_brand", "device_model", "browser", "browser_version",
+	"os", "os_version", "engine", "is_bot", "created_at"}
+
+// writeClicks enriches a batch with geo and user-agent data and stores it
+// with one COPY, bumping click_count for the links Admit didn't count. A row
+// postgres refuses (see isDataError) would fail the COPY for the whole
+// batch, so then the rows are written one at a time and the bad ones are
+// dropped.
+func (s *ClickService) writeClicks(ctx context.Context, batch []clickEvent) error {
+	geo := make(map[string]*GeoResult)
+	agents := make(map[string]utils.UserAgent)
+	rows := make([][]interface{}, len(batch))
+	for i, e := range batch {
+		ua, seen := agents[e.userAgent]
+		if !seen {
+			ua = utils.ParseUA(e.userAgent)
+			agents[e.userAgent] = ua
+		}
+		var country, city string
+		if s.geo != nil {
+			g, seen := geo[e.ip]
+			if !seen {
+				var err error
+				if g, err = s.geo.Lookup(e.ip); err != nil {
+					log.Printf("geo lookup failed for %s: %v", e.ip, err)
+				}
+				geo[e.ip] = g
+			}
+			if g != nil {
+				country, city = utils.Clip(g.Country, 3), utils.Clip(g.City, 100)
+			}
+		}
+		rows[i] = []interface{}{e.linkID, e.revision, utils.Clip(e.ip, 45), e.userAgent, e.referer,
+			country, city, ua.Device, ua.DeviceBrand, ua.DeviceModel, ua.Browser, ua.BrowserVersion,
+			ua.OS, ua.OSVersion, ua.Engine, e.bot, e.at}
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	written := batch
+	err = copyClicks(ctx, tx, rows)
+	if isDataError(err) {
+		written = written[:0:0]
+		for i, row := range rows {
+			err = copyClicks(ctx, tx, [][]interface{}{row})
+			if isDataError(err) {
+				log.Printf("dropping click on link %d: %v", batch[i].linkID, err)
+				continue
+			}
+			if err != nil {
+				break
+			}
+			written = append(written, batch[i])
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("copy clicks: %w", err)
+	}
+
+	uncounted := make(map[int]int)
+	for _, e := range written {
+		if !e.counted && !e.bot {
+			uncounted[e.linkID]++
+		}
+	}
+	if len(uncounted) > 0 {
+		ids := make([]int, 0, len(uncounted))
+		counts := make([]int, 0, len(uncounted))
+		for id, n := range uncounted {
+			ids = append(ids, id)
+			counts = append(counts, n)
+		}
+		_, err := tx.Exec(ctx,
+			`UPDATE links SET click_count = links.click_count + v.n
+			 FROM unnest($1::int[], $2::int[]) AS v(id, n) WHERE links.id = v.id`,
+			ids, counts,
+		)
+		if err != nil {
+			return fmt.Errorf("count clicks: %w", err)
+		}
+	}
+	return tx.Commit(ctx)
+}
+
+// copyClicks copies rows inside a savepoint, so a failure leaves tx usable.
+func copyClicks(ctx context.Context, tx pgx.Tx, rows [][]interface{}) error {
+	sp, err := tx.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer sp.Rollback(ctx)
+	if _, err := sp.CopyFrom(ctx, pgx.Identifier{"clicks"}, clickColumns, pgx.CopyFromRows(rows)); err != nil {
+		return err
+	}
+	return sp.Commit(ctx)
+}
+
+// isTransientError reports whether a failed write may succeed later:
+// postgres couldn't be reached, timed out, was shutting down or out of
+// resources. Anything but an error reply from postgres counts, since that
+// means it never answered.
+func isTransientError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err != nil
+	}
+	for _, class := range []string{"08", "53", "57"} {
+		if strings.HasPrefix(pgErr.Code, class) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDataError reports whether postgres refused the data itself: SQLSTATE
+// class 22 (data exception) or 23 (integrity constraint violation).
+// Writing the same rows again can't succeed.
+func isDataError(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && (strings.HasPrefix(pgErr.Code, "22") || strings.HasPrefix(pgErr.Code, "23"))
+}
+
+// replayClicks writes spooled clicks, leaving out links purged since they
+// were spooled; their foreign key would fail the whole COPY on every retry.
+func (s *ClickService) replayClicks(ctx context.Context, batch []clickEvent) error {
+	ids := make([]int, len(batch))
+	for i, e := range batch {
+		ids[i] = e.linkID
+	}
+	rows, err := s.db.Query(ctx, "SELECT id FROM links WHERE id = ANY($1)", ids)
+	if err != nil {
+		return err
+	}
+	existing, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return err
+	}
+	live := make(map[int]bool, len(existing))
+	for _, id := range existing {
+		live[id] = true
+	}
+
+	kept := batch[:0:0]
+	for _, e := range batch {
+		if live[e.linkID] {
+			kept = append(kept, e)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return s.writeClicks(ctx, kept)
+}
+
+// Record queues a click on a link returned by Resolve. It never blocks and
+// reports false if the click was dropped because the queue is full. Bot
+// clicks are stored but left out of click_count.
+func (s *ClickService) Record(link *models.Link, ip, userAgent, referer string, bot bool) bool {
+	return s.queue.push(clickEvent{
+		linkID:    link.ID,
+		revision:  link.Revision,
+		counted:   link.MaxClicks != nil && !bot,
+		bot:       bot,
+		ip:        ip,
+		userAgent: userAgent,
+		referer:   referer,
+		at:        time.Now(),
+	})
+}
+
+// Start launches the click writers.
+func (s *ClickService) Start() {
+	s.queue.start(s.cfg.ClickWorkers)
+}
+
+// Close stops taking clicks and flushes the queue; call it after the HTTP
+// server has shut down.
+func (s *ClickService) Close(ctx context.Context) error {
+	return s.queue.close(ctx)
+}
+
+// QueueStats reports the click pipeline's counters.
+func (s *ClickService) QueueStats() ClickQueueStats {
+	stats := s.queue.stats()
+	if s.spool != nil {
+		stats.SpoolBytes = s.spool.size()
+	}
+	return stats
+}
+
+// RunSpoolReplayer retries spooled clicks every interval until ctx is
+// cancelled. A replay that fails halfway leaves the rest for the next tick.
+func (s *ClickService) RunSpoolReplayer(ctx context.Context, interval time.Duration) {
+	if s.spool == nil {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			n, err := s.spool.replay(ctx, s.queue.batchSize, func(ctx context.Context, batch []clickEvent) error {
+				// same bound as live writes, which the rollup watermark relies on
+				ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+				defer cancel()
+				return s.replayClicks(ctx, batch)
+			})
+			s.queue.replayed.Add(int64(n))
+			if n > 0 {
+				log.Printf("replayed %d spooled clicks", n)
+			}
+			if err != nil && ctx.Err() == nil {
+				log.Println("click spool replay:", err)
+			}
+		}
+	}
+}
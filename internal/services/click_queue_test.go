@@ -0,0 +1,158 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]clickEvent
+	err     error
+}
+
+func (r *batchRecorder) write(_ context.Context, batch []clickEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.batches = append(r.batches, append([]clickEvent(nil), batch...))
+	return r.err
+}
+
+func (r *batchRecorder) sizes() []int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var sizes []int
+	for _, b := range r.batches {
+		sizes = append(sizes, len(b))
+	}
+	return sizes
+}
+
+func TestClickQueueFlushesFullBatches(t *testing.T) {
+	rec := &batchRecorder{}
+	q := newClickQueue(100, 3, time.Hour, rec.write)
+	q.start(1)
+
+	for i := 0; i < 7; i++ {
+		q.push(clickEvent{linkID: i})
+	}
+	if err := q.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// two full batches, the remainder on close
+	got := rec.sizes()
+	if len(got) != 3 || got[0] != 3 || got[1] != 3 || got[2] != 1 {
+		t.Errorf("batch sizes = %v, want [3 3 1]", got)
+	}
+	if s := q.stats(); s.Enqueued != 7 || s.Written != 7 || s.Batches != 3 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestClickQueueFlushesOnInterval(t *testing.T) {
+	rec := &batchRecorder{}
+	q := newClickQueue(100, 1000, 10*time.Millisecond, rec.write)
+	q.start(1)
+	defer q.close(context.Background())
+
+	q.push(clickEvent{linkID: 1})
+	deadline := time.Now().Add(time.Second)
+	for len(rec.sizes()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if got := rec.sizes(); len(got) != 1 || got[0] != 1 {
+		t.Errorf("batch sizes = %v, want [1]", got)
+	}
+}
+
+func TestClickQueueClampsFlushInterval(t *testing.T) {
+	rec := &batchRecorder{}
+	for _, every := range []time.Duration{0, -time.Second} {
+		q := newClickQueue(100, 1000, every, rec.write)
+		if q.flushEvery != minClickFlush {
+			t.Errorf("flushEvery(%v) = %v, want %v", every, q.flushEvery, minClickFlush)
+		}
+	}
+}
+
+func TestClickQueueDropsWhenFull(t *testing.T) {
+	rec := &batchRecorder{}
+	q := newClickQueue(2, 10, time.Hour, rec.write) // no workers yet
+
+	for i := 0; i < 5; i++ {
+		q.push(clickEvent{linkID: i})
+	}
+	if s := q.stats(); s.Enqueued != 2 || s.Dropped != 3 || s.Depth != 2 {
+		t.Errorf("stats = %+v", s)
+	}
+
+	q.start(1)
+	q.close(context.Background())
+	if q.push(clickEvent{}) {
+		t.Error("push after close succeeded")
+	}
+	if s := q.stats(); s.Written != 2 || s.Dropped != 4 {
+		t.Errorf("stats after close = %+v", s)
+	}
+}
+
+func TestClickQueueCountsFailedBatches(t *testing.T) {
+	rec := &batchRecorder{err: errors.New("db down")}
+	q := newClickQueue(10, 10, time.Hour, rec.write)
+	q.start(2)
+	q.push(clickEvent{})
+	q.push(clickEvent{})
+	q.close(context.Background())
+
+	if s := q.stats(); s.Failed != 2 || s.Written != 0 {
+		t.Errorf("stats = %+v", s)
+	}
+}
+
+func TestIsDataError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{&pgconn.PgError{Code: "22001"}, true}, // value too long, e.g. an ip with a port
+		{fmt.Errorf("copy clicks: %w", &pgconn.PgError{Code: "23503"}), true},
+		{&pgconn.PgError{Code: "08006"}, false},
+		{&pgconn.PgError{Code: "57014"}, false},
+		{context.DeadlineExceeded, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isDataError(tt.err); got != tt.want {
+			t.Errorf("isDataError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestIsTransientError(t *testing.T) {
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{errors.New("dial tcp 10.0.0.5:5432: connect: connection refused"), true},
+		{context.DeadlineExceeded, true},
+		{&pgconn.PgError{Code: "08006"}, true},
+		{&pgconn.PgError{Code: "57P01"}, true},
+		{&pgconn.PgError{Code: "53300"}, true},
+		{&pgconn.PgError{Code: "22001"}, false},
+		{fmt.Errorf("copy clicks: %w", &pgconn.PgError{Code: "23503"}), false},
+		{&pgconn.PgError{Code: "42P01"}, false},
+		{nil, false},
+	}
+	for _, tt := range tests {
+		if got := isTransientError(tt.err); got != tt.want {
+			t.Errorf("isTransientError(%v) = %v, want %v", tt.err, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,222 @@
+package services
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// clickSpool is an append-only file of clicks that could not be written to
+// the database. Replay drains it once the database is back; delivery is
+// at least once, so a crash in the middle of a replay can duplicate a chunk.
+type clickSpool struct {
+	path     string
+	mu       sync.Mutex // serializes appends with the rotation in replay
+	replayMu sync.Mutex
+}
+
+type spooledClick struct {
+	LinkID    int       `json:"link_id"`
+	Revision  int       `json:"revision"`
+	Counted   bool      `json:"counted,omitempty"`
+	Bot       bool      `json:"bot,omitempty"`
+	IP        string    `json:"ip"`
+	UserAgent string    `json:"user_agent"`
+	Referer   string    `json:"referer,omitempty"`
+	At        time.Time `json:"at"`
+}
+
+func newClickSpool(path string) (*clickSpool, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, fmt.Errorf("click spool: %w", err)
+	}
+	return &clickSpool{path: path}, nil
+}
+
+// append writes a batch as NDJSON and syncs it to disk.
+func (s *clickSpool) append(batch []clickEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return appendClicks(s.path, batch)
+}
+
+func appendClicks(path string, batch []clickEvent) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	for _, e := range batch {
+		if err := enc.Encode(spooledClick{
+			LinkID: e.linkID, Revision: e.revision, Counted: e.counted, Bot: e.bot,
+			IP: e.ip, UserAgent: e.userAgent, Referer: e.referer, At: e.at,
+		}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// size reports how many bytes are waiting, including an unfinished replay.
+func (s *clickSpool) size() int64 {
+	var n int64
+	for _, p := range []string{s.path, s.replayPath()} {
+		if fi, err := os.Stat(p); err == nil {
+			n += fi.Size()
+		}
+	}
+	return n
+}
+
+func (s *clickSpool) replayPath() string {
+	return s.path + ".replay"
+}
+
+// rejectedPath holds chunks the database refused, for a person to look at.
+func (s *clickSpool) rejectedPath() string {
+	return s.path + ".rejected"
+}
+
+// replay moves the spool aside and feeds it to write in chunks. If a chunk
+// fails for a transient reason, everything from that chunk on is kept for
+// the next attempt. A chunk the database refuses would fail every attempt
+// and hold back all clicks after it, so it is moved to the rejected file.
+func (s *clickSpool) replay(ctx context.Context, chunk int, write func(context.Context, []clickEvent) error) (int, error) {
+	s.replayMu.Lock()
+	defer s.replayMu.Unlock()
+
+	pending := s.replayPath()
+	// a leftover from an interrupted replay goes first
+	if _, err := os.Stat(pending); errors.Is(err, fs.ErrNotExist) {
+		s.mu.Lock()
+		err := os.Rename(s.path, pending)
+		s.mu.Unlock()
+		if errors.Is(err, fs.ErrNotExist) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	f, err := os.Open(pending)
+	if err != nil {
+		return 0, err
+	}
+	r := bufio.NewReader(f)
+
+	var (
+		replayed   int
+		batch      []clickEvent
+		offset     int64 // start of the first line not yet written
+		readOffset int64
+	)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := write(ctx, batch); err != nil {
+			if isTransientError(err) || ctx.Err() != nil {
+				return err
+			}
+			if rerr := appendClicks(s.rejectedPath(), batch); rerr != nil {
+				return errors.Join(err, rerr)
+			}
+			log.Printf("click spool: moved %d clicks to %s: %v", len(batch), s.rejectedPath(), err)
+		} else {
+			replayed += len(batch)
+		}
+		batch = batch[:0]
+		offset = readOffset
+		return nil
+	}
+
+	for {
+		line, err := r.ReadBytes('\n')
+		readOffset += int64(len(line))
+		if len(line) > 0 {
+			var c spooledClick
+			if jerr := json.Unmarshal(line, &c); jerr != nil {
+				// a torn write from a crash; nothing to recover
+				log.Println("click spool: skipping bad line:", jerr)
+			} else {
+				batch = append(batch, clickEvent{
+					linkID: c.LinkID, revision: c.Revision, counted: c.Counted, bot: c.Bot,
+					ip: c.IP, userAgent: c.UserAgent, referer: c.Referer, at: c.At,
+				})
+			}
+			if len(batch) >= chunk {
+				if werr := flush(); werr != nil {
+					f.Close()
+					return replayed, s.keepFrom(pending, offset, werr)
+				}
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return replayed, s.keepFrom(pending, offset, err)
+		}
+	}
+	if err := flush(); err != nil {
+		f.Close()
+		return replayed, s.keepFrom(pending, offset, err)
+	}
+	f.Close()
+	return replayed, os.Remove(pending)
+}
+
+// keepFrom cuts the already written head off the replay file and returns
+// cause.
+func (s *clickSpool) keepFrom(pending string, offset int64, cause error) error {
+	if offset == 0 {
+		return cause
+	}
+	src, err := os.Open(pending)
+	if err != nil {
+		return errors.Join(cause, err)
+	}
+	defer src.Close()
+	if _, err := src.Seek(offset, io.SeekStart); err != nil {
+		return errors.Join(cause, err)
+	}
+	tmp := pending + ".tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return errors.Join(cause, err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return errors.Join(cause, err)
+	}
+	if err := dst.Sync(); err != nil {
+		dst.Close()
+		return errors.Join(cause, err)
+	}
+	dst.Close()
+	if err := os.Rename(tmp, pending); err != nil {
+		return errors.Join(cause, err)
+	}
+	return cause
+}
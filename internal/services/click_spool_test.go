@@ -0,0 +1,184 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func spoolBatch(ids ...int) []clickEvent {
+	batch := make([]clickEvent, len(ids))
+	for i, id := range ids {
+		batch[i] = clickEvent{linkID: id, revision: 1, ip: "203.0.113.7", userAgent: "test", at: time.Unix(1700000000, 0).UTC()}
+	}
+	return batch
+}
+
+func linkIDs(batches [][]clickEvent) []int {
+	var ids []int
+	for _, b := range batches {
+		for _, e := range b {
+			ids = append(ids, e.linkID)
+		}
+	}
+	return ids
+}
+
+func TestClickSpoolReplaysEverything(t *testing.T) {
+	spool, err := newClickSpool(filepath.Join(t.TempDir(), "spool", "clicks.spool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := spool.append(spoolBatch(1, 2, 3)); err != nil {
+		t.Fatal(err)
+	}
+	if err := spool.append(spoolBatch(4, 5)); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &batchRecorder{}
+	n, err := spool.replay(context.Background(), 2, rec.write)
+	if err != nil || n != 5 {
+		t.Fatalf("replay = %d, %v", n, err)
+	}
+	if got := linkIDs(rec.batches); len(got) != 5 || got[0] != 1 || got[4] != 5 {
+		t.Errorf("replayed ids = %v", got)
+	}
+	if e := rec.batches[0][0]; e.ip != "203.0.113.7" || !e.at.Equal(time.Unix(1700000000, 0)) {
+		t.Errorf("event not round-tripped: %+v", e)
+	}
+	if spool.size() != 0 {
+		t.Errorf("size = %d after full replay", spool.size())
+	}
+
+	// nothing left to do
+	if n, err := spool.replay(context.Background(), 2, rec.write); n != 0 || err != nil {
+		t.Errorf("empty replay = %d, %v", n, err)
+	}
+}
+
+func TestClickSpoolKeepsUnwrittenChunks(t *testing.T) {
+	spool, err := newClickSpool(filepath.Join(t.TempDir(), "clicks.spool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool.append(spoolBatch(1, 2, 3, 4, 5))
+
+	errDown := errors.New("db down")
+	calls := 0
+	failSecond := func(ctx context.Context, batch []clickEvent) error {
+		calls++
+		if calls == 2 {
+			return errDown
+		}
+		return nil
+	}
+	n, err := spool.replay(context.Background(), 2, failSecond)
+	if !errors.Is(err, errDown) || n != 2 {
+		t.Fatalf("replay = %d, %v", n, err)
+	}
+
+	// new clicks spooled meanwhile wait behind the leftover
+	spool.append(spoolBatch(6))
+
+	rec := &batchRecorder{}
+	n, err = spool.replay(context.Background(), 10, rec.write)
+	if err != nil || n != 3 {
+		t.Fatalf("second replay = %d, %v", n, err)
+	}
+	if got := linkIDs(rec.batches); len(got) != 3 || got[0] != 3 || got[2] != 5 {
+		t.Errorf("second replay ids = %v, want [3 4 5]", got)
+	}
+
+	n, err = spool.replay(context.Background(), 10, rec.write)
+	if err != nil || n != 1 {
+		t.Fatalf("third replay = %d, %v", n, err)
+	}
+}
+
+func TestClickSpoolRejectsRefusedChunks(t *testing.T) {
+	spool, err := newClickSpool(filepath.Join(t.TempDir(), "clicks.spool"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	spool.append(spoolBatch(1, 2, 3, 4, 5))
+
+	calls := 0
+	refuseSecond := func(ctx context.Context, batch []clickEvent) error {
+		calls++
+		if calls == 2 {
+			return &pgconn.PgError{Code: "22001"}
+		}
+		return nil
+	}
+	n, err := spool.replay(context.Background(), 2, refuseSecond)
+	if err != nil || n != 3 {
+		t.Fatalf("replay = %d, %v, want 3 and the refused chunk set aside", n, err)
+	}
+	if spool.size() != 0 {
+		t.Errorf("size = %d, want the spool drained", spool.size())
+	}
+	data, err := os.ReadFile(spool.rejectedPath())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Count(string(data), "\n"); lines != 2 {
+		t.Errorf("rejected lines = %d, want 2", lines)
+	}
+}
+
+func TestClickSpoolSkipsTornLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "clicks.spool")
+	spool, _ := newClickSpool(path)
+	spool.append(spoolBatch(1))
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+	f.WriteString(`{"link_id": 2, "revis`)
+	f.Close()
+
+	rec := &batchRecorder{}
+	n, err := spool.replay(context.Background(), 10, rec.write)
+	if err != nil || n != 1 {
+		t.Fatalf("replay = %d, %v", n, err)
+	}
+}
+
+func TestClickQueueSpillsFailedBatches(t *testing.T) {
+	spool, _ := newClickSpool(filepath.Join(t.TempDir(), "clicks.spool"))
+	rec := &batchRecorder{err: errors.New("db down")}
+	q := newClickQueue(10, 10, time.Hour, rec.write)
+	q.spill = spool.append
+	q.start(1)
+	q.push(clickEvent{linkID: 1})
+	q.push(clickEvent{linkID: 2})
+	q.close(context.Background())
+
+	if s := q.stats(); s.Spooled != 2 || s.Failed != 0 {
+		t.Errorf("stats = %+v", s)
+	}
+	if spool.size() == 0 {
+		t.Error("nothing spooled")
+	}
+}
+
+func TestClickQueueDoesNotSpillRefusedBatches(t *testing.T) {
+	spool, _ := newClickSpool(filepath.Join(t.TempDir(), "clicks.spool"))
+	rec := &batchRecorder{err: &pgconn.PgError{Code: "23503"}}
+	q := newClickQueue(10, 10, time.Hour, rec.write)
+	q.spill = spool.append
+	q.start(1)
+	q.push(clickEvent{linkID: 1})
+	q.close(context.Background())
+
+	if s := q.stats(); s.Spooled != 0 || s.Failed != 1 {
+		t.Errorf("stats = %+v", s)
+	}
+	if spool.size() != 0 {
+		t.Error("refused batch was spooled")
+	}
+}
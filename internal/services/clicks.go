@@ -2,87 +2,99 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"log"
+	"time"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 
+	"github.com/shortly/internal/config"
 	"github.com/shortly/internal/models"
 	"github.com/shortly/internal/utils"
 )
 
 type ClickService struct {
-	db  *pgxpool.Pool
-	geo *GeoService
+	db    *pgxpool.Pool
+	geo   *GeoService
+	cfg   *config.Config
+	queue *clickQueue
+	spool *clickSpool
 }
 
-func NewClickService(db *pgxpool.Pool, geo *GeoService) *ClickService {
-	return &ClickService{db: db, geo: geo}
-}
-
-func (s *ClickService) Record(ctx context.Context, linkID int, ip, userAgent, referer string) error {
-	device, browser, os := utils.ParseUserAgent(userAgent)
-
-	var country, city string
-	if s.geo != nil {
-		if result, err := s.geo.Lookup(ip); err == nil {
-			country = result.Country
-			city = result.City
+// NewClickService sets up click ingestion. Batches that can't be written
+// go to a spool file at cfg.ClickSpoolPath, unless that is empty or the
+// file's directory can't be created.
+func NewClickService(db *pgxpool.Pool, geo *GeoService, cfg *config.Config) *ClickService {
+	s := &ClickService{db: db, geo: geo, cfg: cfg}
+	s.queue = newClickQueue(cfg.ClickQueueSize, cfg.ClickBatchSize, cfg.ClickFlushInterval(), s.writeClicks)
+	if cfg.ClickSpoolPath != "" {
+		spool, err := newClickSpool(cfg.ClickSpoolPath)
+		if err != nil {
+			log.Println("warning: clicks will be lost while the database is down:", err)
 		} else {
-			log.Printf("geo lookup failed for %s: %v", ip, err)
+			s.spool = spool
+			s.queue.spill = spool.append
 		}
 	}
-
-	_, err := s.db.Exec(ctx,
-		`INSERT INTO clicks (link_id, ip_address, user_agent, referer, country, city, device, browser, os)
-		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
-		linkID, ip, userAgent, referer, country, city, device, browser, os,
-	)
-	return err
+	return s
 }
 
-func (s *ClickService) GetStats(ctx context.Context, linkID int, days int) (*models.ClickStats, error) {
-	stats := &models.ClickStats{}
-
-	s.db.QueryRow(ctx, "SELECT COUNT(*) FROM clicks WHERE link_id=$1", linkID).Scan(&stats.TotalClicks)
-	s.db.QueryRow(ctx, "SELECT COUNT(DISTINCT ip_address) FROM clicks WHERE link_id=$1", linkID).Scan(&stats.UniqueClicks)
-
-	rows, _ := s.db.Query(ctx,
-		`SELECT DATE(created_at) as day, COUNT(*) FROM clicks
-		 WHERE link_id=$1 AND created_at >= NOW() - INTERVAL '1 day' * $2
-		 GROUP BY day ORDER BY day`, linkID, days)
-	if rows != nil {
-		defer rows.Close()
-		for rows.Next() {
-			var dc models.DayCount
-			rows.Scan(&dc.Date, &dc.Count)
-			stats.ClicksByDay = append(stats.ClicksByDay, dc)
+// List returns a link's raw clicks newest first, keyset-paginated on
+// (created_at, id). An empty cursor starts at the newest click.
+func (s *ClickService) List(ctx context.Context, linkID int, cursor string, limit int) (*models.ClickListResponse, error) {
+	ks := keyset{expr: "created_at", idExpr: "id", desc: true}
+	var value interface{}
+	if cursor != "" {
+		c, err := utils.DecodeCursor(cursor)
+		if err != nil || c.Key != "created_at" {
+			return nil, utils.ErrInvalidCursor
+		}
+		if value, err = time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return nil, utils.ErrInvalidCursor
 		}
+		ks.cursor = &c
 	}
 
-	stats.TopReferrers = s.getTopN(ctx, linkID, "referer", 10)
-	stats.TopCountries = s.getTopN(ctx, linkID, "country", 10)
-	stats.TopBrowsers = s.getTopN(ctx, linkID, "browser", 5)
-	stats.TopDevices = s.getTopN(ctx, linkID, "device", 5)
-	stats.TopOS = s.getTopN(ctx, linkID, "os", 5)
-
-	return stats, nil
-}
+	where := "link_id=$1"
+	args := []interface{}{linkID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if cond := ks.where(arg, value); cond != "" {
+		where += " AND " + cond
+	}
 
-func (s *ClickService) getTopN(ctx context.Context, linkID int, column string, limit int) []models.NameCount {
-	var results []models.NameCount
 	rows, err := s.db.Query(ctx,
-		`SELECT COALESCE(NULLIF(`+column+`, ''), 'direct') as name, COUNT(*) as count
-		 FROM clicks WHERE link_id=$1 GROUP BY name ORDER BY count DESC LIMIT $2`,
-		linkID, limit,
+		`SELECT id, link_id, COALESCE(revision, 0), COALESCE(ip_address, ''), COALESCE(user_agent, ''),
+		        COALESCE(referer, ''), COALESCE(country, ''), COALESCE(city, ''), COALESCE(device, ''),
+		        COALESCE(device_brand, ''), COALESCE(device_model, ''), COALESCE(browser, ''),
+		        COALESCE(browser_version, ''), COALESCE(os, ''), COALESCE(os_version, ''),
+		        COALESCE(engine, ''), is_bot, created_at
+		 FROM clicks WHERE `+where+` ORDER BY `+ks.orderBy()+` LIMIT `+arg(limit+1),
+		args...,
 	)
 	if err != nil {
-		return results
+		return nil, err
 	}
 	defer rows.Close()
+
+	clicks := []models.Click{}
 	for rows.Next() {
-		var nc models.NameCount
-		rows.Scan(&nc.Name, &nc.Count)
-		results = append(results, nc)
+		var c models.Click
+		if err := rows.Scan(&c.ID, &c.LinkID, &c.Revision, &c.IPAddress, &c.UserAgent, &c.Referer,
+			&c.Country, &c.City, &c.Device, &c.DeviceBrand, &c.DeviceModel, &c.Browser, &c.BrowserVersion,
+			&c.OS, &c.OSVersion, &c.Engine, &c.IsBot, &c.CreatedAt); err != nil {
+			return nil, err
+		}
+		clicks = append(clicks, c)
 	}
-	return results
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	clicks, next, prev := keysetPage(clicks, limit, ks.cursor, func(c models.Click) utils.Cursor {
+		return utils.Cursor{Key: "created_at", Value: c.CreatedAt.Format(time.RFC3339Nano), ID: c.ID}
+	})
+	return &models.ClickListResponse{Clicks: clicks, NextCursor: next, PrevCursor: prev}, nil
 }
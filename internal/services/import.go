@@ -0,0 +1,69 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/shortly/internal/importers"
+	"github.com/shortly/internal/models"
+)
+
+// ImportExternal creates links from another shortener's export, keeping
+// their short codes. Codes already taken here are reported as conflicts and
+// skipped; everything else goes through Create's validation, except that
+// codes only need to be url-safe (see utils.IsValidImportedCode). With
+// seedClicks the source's click totals are carried over as imported_clicks.
+func (s *LinkService) ImportExternal(ctx context.Context, userID int, source string, records []importers.Record, seedClicks bool) (*models.ExternalImportReport, error) {
+	report := &models.ExternalImportReport{Source: source, Total: len(records)}
+
+	codes := make([]string, 0, len(records))
+	for _, r := range records {
+		if r.ShortCode != "" {
+			codes = append(codes, r.ShortCode)
+		}
+	}
+	taken, err := s.existingCodes(ctx, codes)
+	if err != nil {
+		return nil, err
+	}
+	conflict := make(map[string]bool, len(taken))
+	for _, c := range taken {
+		conflict[c] = true
+	}
+
+	for _, r := range records {
+		issue := models.ImportIssue{ShortCode: r.ShortCode, URL: r.URL}
+		if r.ShortCode != "" && conflict[r.ShortCode] {
+			issue.Error = ErrCodeTaken.Error()
+			report.Conflicts = append(report.Conflicts, issue)
+			continue
+		}
+
+		opts := createOptions{importedCode: true, createdAt: r.CreatedAt}
+		if seedClicks {
+			opts.importedClicks = r.Clicks
+		}
+		_, err := s.create(ctx, userID, models.CreateLinkRequest{
+			URL:        r.URL,
+			Title:      r.Title,
+			CustomCode: r.ShortCode,
+			Tags:       r.Tags,
+		}, opts)
+		if err != nil {
+			issue.Error = err.Error()
+			if errors.Is(err, ErrCodeTaken) {
+				report.Conflicts = append(report.Conflicts, issue)
+			} else {
+				report.Errors = append(report.Errors, issue)
+			}
+			continue
+		}
+		// a repeat of this code later in the same file is a conflict too
+		if r.ShortCode != "" {
+			conflict[r.ShortCode] = true
+		}
+		report.Imported++
+	}
+
+	return report, nil
+}
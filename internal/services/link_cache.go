@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"time"
+
+	"github.com/shortly/internal/cache"
+	"github.com/shortly/internal/models"
+)
+
+const (
+	linkCacheTTL = 24 * time.Hour
+	// how long a short code that doesn't exist is remembered as missing;
+	// creating a link under it overwrites the entry right away
+	negativeCacheTTL = 30 * time.Second
+)
+
+// linkEntry is what the redirect cache holds per short code: everything
+// Resolve needs to accept or reject a visit without asking the database.
+// Single-use links are never cached; click limits are still enforced by
+// Admit against the counter in the database. NotFound entries record codes
+// that don't resolve, so scanners probing random paths stay off postgres.
+type linkEntry struct {
+	NotFound    bool       `json:"not_found,omitempty"`
+	ID          int        `json:"id"`
+	Revision    int        `json:"revision"`
+	URL         string     `json:"url"`
+	IsActive    bool       `json:"is_active"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	MaxClicks   *int       `json:"max_clicks,omitempty"`
+	HasPassword bool       `json:"has_password"`
+}
+
+func linkCacheKey(code string) string {
+	return "link:" + code
+}
+
+func (e linkEntry) link(code string) *models.Link {
+	return &models.Link{
+		ID:          e.ID,
+		ShortCode:   code,
+		OriginalURL: e.URL,
+		IsActive:    e.IsActive,
+		ExpiresAt:   e.ExpiresAt,
+		MaxClicks:   e.MaxClicks,
+		HasPassword: e.HasPassword,
+		Revision:    e.Revision,
+	}
+}
+
+// cacheLink refreshes the redirect entry for link. Links that must always
+// go to the database are evicted instead, and an entry never outlives the
+// link's expiry.
+func (s *LinkService) cacheLink(ctx context.Context, link *models.Link) {
+	ttl := linkCacheTTL
+	if link.ExpiresAt != nil {
+		if d := time.Until(*link.ExpiresAt); d < ttl {
+			ttl = d
+		}
+	}
+	if link.SingleUse || link.DeletedAt != nil || ttl <= 0 {
+		s.evictLinks(ctx, link.ShortCode)
+		return
+	}
+	_ = s.cache.Set(ctx, linkCacheKey(link.ShortCode), linkEntry{
+		ID:          link.ID,
+		Revision:    link.Revision,
+		URL:         link.OriginalURL,
+		IsActive:    link.IsActive,
+		ExpiresAt:   link.ExpiresAt,
+		MaxClicks:   link.MaxClicks,
+		HasPassword: link.HasPassword,
+	}, ttl)
+}
+
+// cacheMissing remembers that code doesn't resolve. Misses are what
+// scanners generate, so they are written without an invalidation broadcast.
+func (s *LinkService) cacheMissing(ctx context.Context, code string) {
+	_ = cache.SetQuiet(ctx, s.cache, linkCacheKey(code), linkEntry{NotFound: true}, negativeCacheTTL)
+}
+
+// evictLinks drops the redirect entries for the given short codes.
+func (s *LinkService) evictLinks(ctx context.Context, codes ...string) {
+	if len(codes) == 0 {
+		return
+	}
+	keys := make([]string, len(codes))
+	for i, code := range codes {
+		keys[i] = linkCacheKey(code)
+	}
+	_ = s.cache.DeleteMany(ctx, keys...)
+}
+
+// checkLink rejects visits to links that are used up, disabled or expired.
+func checkLink(link *models.Link) error {
+	if link.ConsumedAt != nil {
+		return ErrLinkUsed
+	}
+	if !link.IsActive {
+		return ErrLinkDisabled
+	}
+	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
+		return ErrLinkExpired
+	}
+	// early out only; Admit is what actually enforces the limit
+	if limitReached(link.ClickCount, link.MaxClicks) {
+		return ErrClickLimit
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shortly/internal/models"
+)
+
+// linkDomainExpr extracts the lower-cased host from original_url. It must
+// match the expression index created in the migrations to be usable.
+const linkDomainExpr = `lower(substring(l.original_url from '^[a-zA-Z]+://([^/:?#]+)'))`
+
+// clickCountExpr is a link's total clicks, including any imported total.
+const clickCountExpr = `(l.click_count + l.imported_clicks)`
+
+// linkSortColumns maps sort keys to SQL expressions. They are expressions
+// rather than output aliases so keyset conditions can use them in WHERE.
+var linkSortColumns = map[string]string{
+	"created_at": "l.created_at",
+	"clicks":     clickCountExpr,
+	"title":      "COALESCE(l.title, '')",
+}
+
+// linkCursorKey returns the sort key value of l as stored in a cursor.
+func linkCursorKey(sortKey string, l models.Link) string {
+	switch sortKey {
+	case "clicks":
+		return strconv.Itoa(l.ClickCount)
+	case "title":
+		return l.Title
+	default:
+		return l.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
+
+// linkCursorValue converts a cursor value back to the sort key's Go type.
+func linkCursorValue(sortKey, v string) (interface{}, error) {
+	switch sortKey {
+	case "clicks":
+		return strconv.ParseInt(v, 10, 64)
+	case "title":
+		return v, nil
+	default:
+		return time.Parse(time.RFC3339Nano, v)
+	}
+}
+
+// ValidateLinkFilter rejects unknown sort keys, orders and statuses.
+func ValidateLinkFilter(f models.LinkFilter) error {
+	if f.Sort != "" {
+		if _, ok := linkSortColumns[f.Sort]; !ok {
+			return errors.New("sort must be one of created_at, clicks, title")
+		}
+	}
+	if f.Order != "" && f.Order != "asc" && f.Order != "desc" {
+		return errors.New("order must be asc or desc")
+	}
+	switch f.Status {
+	case "", "active", "inactive", "expired":
+	default:
+		return errors.New("status must be one of active, inactive, expired")
+	}
+	return nil
+}
+
+// buildLinkFilter turns a filter into a WHERE clause over links aliased as l,
+// with placeholders numbered from $1.
+func buildLinkFilter(userID int, f models.LinkFilter) (string, []interface{}) {
+	conds := []string{"l.user_id=$1", "l.deleted_at IS NULL"}
+	if f.Trashed {
+		conds[1] = "l.deleted_at IS NOT NULL"
+	}
+	args := []interface{}{userID}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if q := strings.TrimSpace(f.Query); q != "" {
+		p := arg("%" + escapeLike(q) + "%")
+		conds = append(conds, fmt.Sprintf("(l.title ILIKE %[1]s OR l.original_url ILIKE %[1]s OR l.short_code ILIKE %[1]s)", p))
+	}
+	if f.Tag != "" {
+		conds = append(conds, fmt.Sprintf(
+			"EXISTS (SELECT 1 FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = l.id AND t.name = %s)",
+			arg(f.Tag)))
+	}
+	switch f.Status {
+	case "active":
+		conds = append(conds, "l.is_active AND (l.expires_at IS NULL OR l.expires_at > NOW())")
+	case "inactive":
+		conds = append(conds, "NOT l.is_active")
+	case "expired":
+		conds = append(conds, "l.expires_at <= NOW()")
+	}
+	if f.CreatedAfter != nil {
+		conds = append(conds, "l.created_at >= "+arg(*f.CreatedAfter))
+	}
+	if f.CreatedBefore != nil {
+		conds = append(conds, "l.created_at < "+arg(*f.CreatedBefore))
+	}
+	if d := strings.ToLower(strings.TrimSpace(f.Domain)); d != "" {
+		p := arg(d)
+		conds = append(conds, fmt.Sprintf("(%[1]s = %[2]s OR %[1]s LIKE '%%.' || %[2]s)", linkDomainExpr, p))
+	}
+
+	return strings.Join(conds, " AND "), args
+}
+
+// linkOrderBy returns the ORDER BY clause for a filter, with id as tiebreaker.
+func linkOrderBy(f models.LinkFilter) string {
+	col, ok := linkSortColumns[f.Sort]
+	if !ok {
+		col = linkSortColumns["created_at"]
+	}
+	dir := "DESC"
+	if f.Order == "asc" {
+		dir = "ASC"
+	}
+	return fmt.Sprintf("%s %s, l.id %s", col, dir, dir)
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
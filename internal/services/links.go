@@ -4,9 +4,12 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/shortly/internal/cache"
@@ -15,17 +18,46 @@ import (
 	"github.com/shortly/internal/utils"
 )
 
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrCodeTaken    = errors.New("short code already taken")
+	ErrLinkUsed     = errors.New("link already used")
+	ErrClickLimit   = errors.New("click limit reached")
+	ErrLinkDisabled = errors.New("link disabled")
+	ErrLinkExpired  = errors.New("link expired")
+)
+
+// querier is satisfied by both the pool and a transaction.
+type querier interface {
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+}
+
 type LinkService struct {
 	db    *pgxpool.Pool
-	cache *cache.RedisCache
+	cache cache.Cache
 	cfg   *config.Config
+
+	lookups cache.Group[*models.Link]
 }
 
-func NewLinkService(db *pgxpool.Pool, cache *cache.RedisCache, cfg *config.Config) *LinkService {
+func NewLinkService(db *pgxpool.Pool, cache cache.Cache, cfg *config.Config) *LinkService {
 	return &LinkService{db: db, cache: cache, cfg: cfg}
 }
 
 func (s *LinkService) Create(ctx context.Context, userID int, req models.CreateLinkRequest) (*models.Link, error) {
+	return s.create(ctx, userID, req, createOptions{})
+}
+
+// createOptions covers what imports need beyond a CreateLinkRequest.
+type createOptions struct {
+	inactive       bool       // store the link switched off from the start
+	importedCode   bool       // CustomCode comes from another shortener, see utils.IsValidImportedCode
+	importedClicks int        // clicks counted by another shortener
+	createdAt      *time.Time // when the link was created elsewhere; now if nil
+}
+
+func (s *LinkService) create(ctx context.Context, userID int, req models.CreateLinkRequest, opts createOptions) (*models.Link, error) {
 	if !utils.IsValidURL(req.URL) {
 		return nil, errors.New("invalid url")
 	}
@@ -34,14 +66,18 @@ func (s *LinkService) Create(ctx context.Context, userID int, req models.CreateL
 	var err error
 
 	if req.CustomCode != "" {
-		if !utils.IsValidCustomCode(req.CustomCode) {
-			return nil, errors.New("invalid custom code: 3-20 alphanumeric chars, hyphens, underscores")
+		if opts.importedCode {
+			if !utils.IsValidImportedCode(req.CustomCode) {
+				return nil, errors.New("invalid short code: up to 20 url-safe chars, not a reserved route")
+			}
+		} else if !utils.IsValidCustomCode(req.CustomCode) {
+			return nil, errors.New("invalid custom code: 3-20 alphanumeric chars, hyphens, underscores, not a reserved route")
 		}
 		// check if taken
 		var exists bool
 		s.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM links WHERE short_code=$1)", req.CustomCode).Scan(&exists)
 		if exists {
-			return nil, errors.New("short code already taken")
+			return nil, ErrCodeTaken
 		}
 		code = req.CustomCode
 	} else {
@@ -61,127 +97,710 @@ func (s *LinkService) Create(ctx context.Context, userID int, req models.CreateL
 		}
 	}
 
-	var expiresAt *time.Time
-	if req.ExpiresIn > 0 {
+	expiresAt := req.ExpiresAt
+	if expiresAt == nil && req.ExpiresIn > 0 {
 		t := time.Now().Add(time.Duration(req.ExpiresIn) * 24 * time.Hour)
 		expiresAt = &t
 	}
 
+	var passwordHash *string
+	if req.Password != "" {
+		h, err := HashLinkPassword(req.Password)
+		if err != nil {
+			return nil, err
+		}
+		passwordHash = &h
+	}
+
+	// the link and its first revision stand or fall together
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
 	link := &models.Link{}
-	err = s.db.QueryRow(ctx,
-		`INSERT INTO links (short_code, original_url, title, user_id, expires_at, max_clicks)
-		 VALUES ($1, $2, $3, $4, $5, $6)
-		 RETURNING id, short_code, original_url, title, user_id, is_active, expires_at, max_clicks, created_at, updated_at`,
-		code, req.URL, req.Title, userID, expiresAt, req.MaxClicks,
+	err = tx.QueryRow(ctx,
+		`INSERT INTO links (short_code, original_url, title, user_id, expires_at, max_clicks, password_hash, single_use, is_active,
+		                    imported_clicks, created_at)
+		 VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, COALESCE($11, NOW()))
+		 RETURNING id, short_code, original_url, title, user_id, is_active, expires_at, max_clicks, single_use, revision, created_at, updated_at`,
+		code, req.URL, req.Title, userID, expiresAt, req.MaxClicks, passwordHash, req.SingleUse, !opts.inactive,
+		opts.importedClicks, opts.createdAt,
 	).Scan(&link.ID, &link.ShortCode, &link.OriginalURL, &link.Title, &link.UserID,
-		&link.IsActive, &link.ExpiresAt, &link.MaxClicks, &link.CreatedAt, &link.UpdatedAt)
+		&link.IsActive, &link.ExpiresAt, &link.MaxClicks, &link.SingleUse, &link.Revision, &link.CreatedAt, &link.UpdatedAt)
 	if err != nil {
 		return nil, fmt.Errorf("insert link: %w", err)
 	}
-
-	link.ShortURL = fmt.Sprintf("%s/%s", s.cfg.BaseURL, link.ShortCode)
+	if err := s.recordRevision(ctx, tx, link.ID, userID); err != nil {
+		return nil, err
+	}
 
 	// handle tags
 	if len(req.Tags) > 0 {
-		for _, tagName := range req.Tags {
-			var tagID int
-			err := s.db.QueryRow(ctx,
-				`INSERT INTO tags (name, user_id) VALUES ($1, $2) ON CONFLICT (name, user_id) DO UPDATE SET name=$1 RETURNING id`,
-				tagName, userID,
-			).Scan(&tagID)
-			if err != nil {
-				continue
-			}
-			s.db.Exec(ctx, "INSERT INTO link_tags (link_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", link.ID, tagID)
-		}
+		s.attachTags(ctx, tx, link.ID, userID, req.Tags)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
 	}
 
-	// cache the redirect
-	_ = s.cache.Set(ctx, "link:"+code, link.OriginalURL, 24*time.Hour)
+	link.ShortURL = fmt.Sprintf("%s/%s", s.cfg.BaseURL, link.ShortCode)
+	link.HasPassword = passwordHash != nil
+	if len(req.Tags) > 0 {
+		link.Tags, _ = s.linkTags(ctx, link.ID)
+	}
+
+	s.cacheLink(ctx, link)
 
 	return link, nil
 }
 
-func (s *LinkService) Resolve(ctx context.Context, code string) (string, int, error) {
-	// try cache first
-	var cachedURL string
-	if err := s.cache.Get(ctx, "link:"+code, &cachedURL); err == nil {
-		// get link id for click tracking
-		var linkID int
-		s.db.QueryRow(ctx, "SELECT id FROM links WHERE short_code=$1", code).Scan(&linkID)
-		return cachedURL, linkID, nil
+// Resolve returns the link a short code currently points to, including the
+// revision the click should be attributed to. HasPassword is always filled
+// in; callers must not redirect protected links without an unlock. Cache
+// hits, including cached misses, are decided from the cached entry alone.
+func (s *LinkService) Resolve(ctx context.Context, code string) (*models.Link, error) {
+	var entry linkEntry
+	if err := s.cache.Get(ctx, linkCacheKey(code), &entry); err == nil {
+		if entry.NotFound {
+			return nil, ErrNotFound
+		}
+		if entry.ID != 0 {
+			link := entry.link(code)
+			if err := checkLink(link); err != nil {
+				return nil, err
+			}
+			return link, nil
+		}
 	}
 
-	var link models.Link
+	// concurrent misses on the same code share one query, which must not be
+	// cut short by whichever request happened to start it going away
+	shared, err, _ := s.lookups.Do(code, func() (*models.Link, error) {
+		return s.loadLink(context.WithoutCancel(ctx), code)
+	})
+	if err != nil {
+		return nil, err
+	}
+	link := *shared
+	if err := checkLink(&link); err != nil {
+		return nil, err
+	}
+	return &link, nil
+}
+
+// loadLink reads the redirect state for code from the database and caches
+// it, or caches the miss.
+func (s *LinkService) loadLink(ctx context.Context, code string) (*models.Link, error) {
+	link := &models.Link{ShortCode: code}
 	err := s.db.QueryRow(ctx,
-		"SELECT id, original_url, is_active, expires_at, max_clicks FROM links WHERE short_code=$1",
+		`SELECT id, original_url, is_active, expires_at, max_clicks, revision, COALESCE(password_hash, '') <> '',
+		        single_use, consumed_at, click_count
+		 FROM links WHERE short_code=$1 AND deleted_at IS NULL`,
 		code,
-	).Scan(&link.ID, &link.OriginalURL, &link.IsActive, &link.ExpiresAt, &link.MaxClicks)
+	).Scan(&link.ID, &link.OriginalURL, &link.IsActive, &link.ExpiresAt, &link.MaxClicks, &link.Revision, &link.HasPassword,
+		&link.SingleUse, &link.ConsumedAt, &link.ClickCount)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return "", 0, errors.New("not found")
+			s.cacheMissing(ctx, code)
+			return nil, ErrNotFound
 		}
-		return "", 0, err
+		return nil, err
 	}
 
-	if !link.IsActive {
-		return "", 0, errors.New("link disabled")
+	// cached whatever its state so disabled links are rejected from the cache too
+	s.cacheLink(ctx, link)
+	return link, nil
+}
+
+// Admit lets one visit through a resolved link. Single-use links are claimed
+// with Consume. Links with max_clicks take a slot from click_count in the
+// same conditional update that checks the limit, so concurrent visits can't
+// overshoot it; unlimited links are counted when the click is recorded.
+func (s *LinkService) Admit(ctx context.Context, link *models.Link) error {
+	if link.SingleUse {
+		if err := s.Consume(ctx, link.ID); err != nil {
+			return err
+		}
 	}
-	if link.ExpiresAt != nil && time.Now().After(*link.ExpiresAt) {
-		return "", 0, errors.New("link expired")
+	if link.MaxClicks == nil {
+		return nil
 	}
-	if link.MaxClicks != nil {
-		var count int
-		s.db.QueryRow(ctx, "SELECT COUNT(*) FROM clicks WHERE link_id=$1", link.ID).Scan(&count)
-		if count >= *link.MaxClicks {
-			return "", 0, errors.New("click limit reached")
-		}
+	tag, err := s.db.Exec(ctx,
+		"UPDATE links SET click_count=click_count+1 WHERE id=$1 AND (max_clicks IS NULL OR click_count < max_clicks)", link.ID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrClickLimit
 	}
+	return nil
+}
+
+// ShadowedCodes lists live links whose code is a reserved route, so they
+// can't be opened. Codes like that were accepted before they were reserved.
+func (s *LinkService) ShadowedCodes(ctx context.Context) ([]string, error) {
+	rows, err := s.db.Query(ctx,
+		"SELECT short_code FROM links WHERE lower(short_code) = ANY($1) AND deleted_at IS NULL ORDER BY short_code",
+		utils.ReservedCodes,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return pgx.CollectRows(rows, pgx.RowTo[string])
+}
+
+// AdmitBot checks a bot's visit against the link's click limit without
+// taking a slot, so crawlers can't use up a limit but don't get past one
+// either. The redirect cache doesn't carry click counts, so this reads the
+// database for every link with a limit.
+func (s *LinkService) AdmitBot(ctx context.Context, link *models.Link) error {
+	if link.MaxClicks == nil {
+		return nil
+	}
+	var count int
+	var maxClicks *int
+	err := s.db.QueryRow(ctx,
+		"SELECT click_count, max_clicks FROM links WHERE id=$1", link.ID,
+	).Scan(&count, &maxClicks)
+	if err != nil {
+		return err
+	}
+	if limitReached(count, maxClicks) {
+		return ErrClickLimit
+	}
+	return nil
+}
+
+// limitReached reports whether a link with count clicks has run out.
+func limitReached(count int, maxClicks *int) bool {
+	return maxClicks != nil && count >= *maxClicks
+}
 
-	_ = s.cache.Set(ctx, "link:"+code, link.OriginalURL, 24*time.Hour)
-	return link.OriginalURL, link.ID, nil
+// Consume marks a single-use link as used. The conditional update is the
+// compare-and-set: of any number of concurrent visits exactly one gets nil,
+// the rest get ErrLinkUsed.
+func (s *LinkService) Consume(ctx context.Context, linkID int) error {
+	tag, err := s.db.Exec(ctx,
+		"UPDATE links SET consumed_at=NOW() WHERE id=$1 AND single_use AND consumed_at IS NULL", linkID,
+	)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return ErrLinkUsed
+	}
+	return nil
 }
 
-func (s *LinkService) ListByUser(ctx context.Context, userID, page, perPage int) (*models.LinkListResponse, error) {
+// linkListColumns is the select list scanned by listLinks.
+const linkListColumns = `l.id, l.short_code, l.original_url, COALESCE(l.title, ''), l.user_id, l.is_active,
+		        l.expires_at, l.max_clicks, l.revision, COALESCE(l.password_hash, '') <> '',
+		        l.single_use, l.consumed_at, l.created_at, l.updated_at, l.deleted_at,
+		        ` + clickCountExpr + ` as click_count`
+
+func (s *LinkService) ListByUser(ctx context.Context, userID int, filter models.LinkFilter, page, perPage int) (*models.LinkListResponse, error) {
 	offset := (page - 1) * perPage
+	where, args := buildLinkFilter(userID, filter)
 
 	var total int
-	s.db.QueryRow(ctx, "SELECT COUNT(*) FROM links WHERE user_id=$1", userID).Scan(&total)
+	s.db.QueryRow(ctx, "SELECT COUNT(*) FROM links l WHERE "+where, args...).Scan(&total)
 
-	rows, err := s.db.Query(ctx,
-		`SELECT l.id, l.short_code, l.original_url, l.title, l.user_id, l.is_active,
-		        l.expires_at, l.max_clicks, l.created_at, l.updated_at,
-		        COALESCE((SELECT COUNT(*) FROM clicks WHERE link_id=l.id), 0) as click_count
-		 FROM links l WHERE l.user_id=$1 ORDER BY l.created_at DESC LIMIT $2 OFFSET $3`,
-		userID, perPage, offset,
+	args = append(args, perPage, offset)
+	links, err := s.listLinks(ctx,
+		`SELECT `+linkListColumns+`
+		 FROM links l WHERE `+where+`
+		 ORDER BY `+linkOrderBy(filter)+fmt.Sprintf(" LIMIT $%d OFFSET $%d", len(args)-1, len(args)),
+		args...,
 	)
 	if err != nil {
 		return nil, err
 	}
+
+	return &models.LinkListResponse{Links: links, Total: total, Page: page, PerPage: perPage}, nil
+}
+
+// ListByUserCursor is the keyset-paginated variant of ListByUser. An empty
+// cursor starts at the first page; it skips the COUNT(*) and stays stable
+// while links are being created.
+func (s *LinkService) ListByUserCursor(ctx context.Context, userID int, filter models.LinkFilter, cursor string, limit int) (*models.LinkListResponse, error) {
+	sortKey := filter.Sort
+	if sortKey == "" {
+		sortKey = "created_at"
+	}
+
+	ks := keyset{expr: linkSortColumns[sortKey], idExpr: "l.id", desc: filter.Order != "asc"}
+	var value interface{}
+	if cursor != "" {
+		c, err := utils.DecodeCursor(cursor)
+		if err != nil || c.Key != sortKey {
+			return nil, utils.ErrInvalidCursor
+		}
+		if value, err = linkCursorValue(sortKey, c.Value); err != nil {
+			return nil, utils.ErrInvalidCursor
+		}
+		ks.cursor = &c
+	}
+
+	where, args := buildLinkFilter(userID, filter)
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if cond := ks.where(arg, value); cond != "" {
+		where += " AND " + cond
+	}
+
+	links, err := s.listLinks(ctx,
+		`SELECT `+linkListColumns+`
+		 FROM links l WHERE `+where+`
+		 ORDER BY `+ks.orderBy()+` LIMIT `+arg(limit+1),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	links, next, prev := keysetPage(links, limit, ks.cursor, func(l models.Link) utils.Cursor {
+		return utils.Cursor{Key: sortKey, Value: linkCursorKey(sortKey, l), ID: l.ID}
+	})
+	return &models.LinkListResponse{Links: links, PerPage: limit, NextCursor: next, PrevCursor: prev}, nil
+}
+
+// listLinks runs a query selecting linkListColumns and fills in short URLs and tags.
+func (s *LinkService) listLinks(ctx context.Context, query string, args ...interface{}) ([]models.Link, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
 	defer rows.Close()
 
-	var links []models.Link
+	links := []models.Link{}
 	for rows.Next() {
 		var l models.Link
 		err := rows.Scan(&l.ID, &l.ShortCode, &l.OriginalURL, &l.Title, &l.UserID,
-			&l.IsActive, &l.ExpiresAt, &l.MaxClicks, &l.CreatedAt, &l.UpdatedAt, &l.ClickCount)
+			&l.IsActive, &l.ExpiresAt, &l.MaxClicks, &l.Revision, &l.HasPassword,
+			&l.SingleUse, &l.ConsumedAt, &l.CreatedAt, &l.UpdatedAt, &l.DeletedAt, &l.ClickCount)
 		if err != nil {
 			continue
 		}
 		l.ShortURL = fmt.Sprintf("%s/%s", s.cfg.BaseURL, l.ShortCode)
 		links = append(links, l)
 	}
-
-	return &models.LinkListResponse{Links: links, Total: total, Page: page, PerPage: perPage}, nil
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if err := s.loadTags(ctx, links); err != nil {
+		return nil, err
+	}
+	return links, nil
 }
 
+// Delete moves a link to the trash. It stops resolving immediately but keeps
+// its clicks until PurgeTrash removes it for good.
 func (s *LinkService) Delete(ctx context.Context, linkID, userID int) error {
-	res, err := s.db.Exec(ctx, "DELETE FROM links WHERE id=$1 AND user_id=$2", linkID, userID)
+	var code string
+	err := s.db.QueryRow(ctx,
+		"UPDATE links SET deleted_at=NOW() WHERE id=$1 AND user_id=$2 AND deleted_at IS NULL RETURNING short_code",
+		linkID, userID,
+	).Scan(&code)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
 		return err
 	}
+
+	s.evictLinks(ctx, code)
+	return nil
+}
+
+// ListTrash lists the user's deleted links that have not been purged yet.
+func (s *LinkService) ListTrash(ctx context.Context, userID, page, perPage int) (*models.LinkListResponse, error) {
+	return s.ListByUser(ctx, userID, models.LinkFilter{Trashed: true}, page, perPage)
+}
+
+// Restore takes a link back out of the trash.
+func (s *LinkService) Restore(ctx context.Context, linkID, userID int) (*models.Link, error) {
+	res, err := s.db.Exec(ctx,
+		"UPDATE links SET deleted_at=NULL, updated_at=NOW() WHERE id=$1 AND user_id=$2 AND deleted_at IS NOT NULL",
+		linkID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
 	if res.RowsAffected() == 0 {
-		return errors.New("not found")
+		return nil, ErrNotFound
+	}
+
+	links, err := s.listLinks(ctx, `SELECT `+linkListColumns+` FROM links l WHERE l.id=$1`, linkID)
+	if err != nil {
+		return nil, err
+	}
+	if len(links) == 0 {
+		return nil, ErrNotFound
+	}
+	s.cacheLink(ctx, &links[0])
+	return &links[0], nil
+}
+
+// PurgeTrash permanently deletes links that have been in the trash longer
+// than retention, together with their clicks.
+func (s *LinkService) PurgeTrash(ctx context.Context, retention time.Duration) (int64, error) {
+	rows, err := s.db.Query(ctx,
+		"DELETE FROM links WHERE deleted_at IS NOT NULL AND deleted_at < $1 RETURNING short_code",
+		time.Now().Add(-retention),
+	)
+	if err != nil {
+		return 0, err
+	}
+	codes, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return 0, err
+	}
+	// evicted when trashed already; this only catches stragglers
+	s.evictLinks(ctx, codes...)
+	return int64(len(codes)), nil
+}
+
+// RunTrashPurger calls PurgeTrash every interval until ctx is canceled.
+func (s *LinkService) RunTrashPurger(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := s.PurgeTrash(ctx, s.cfg.TrashRetention())
+		if err != nil {
+			log.Println("trash purge:", err)
+		} else if n > 0 {
+			log.Printf("trash purge: removed %d links", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Update applies a partial update to a link owned by userID and evicts the
+// cached redirect so the change is live immediately.
+func (s *LinkService) Update(ctx context.Context, linkID, userID int, req models.UpdateLinkRequest) (*models.Link, error) {
+	var sets []string
+	args := []interface{}{linkID, userID}
+	set := func(column string, value interface{}) {
+		args = append(args, value)
+		sets = append(sets, fmt.Sprintf("%s=$%d", column, len(args)))
+	}
+
+	if req.URL != nil {
+		if !utils.IsValidURL(*req.URL) {
+			return nil, errors.New("invalid url")
+		}
+		set("original_url", *req.URL)
+	}
+	if req.Title != nil {
+		set("title", *req.Title)
+	}
+	if req.IsActive != nil {
+		set("is_active", *req.IsActive)
+	}
+	if req.ExpiresAt != nil {
+		var expiresAt *time.Time
+		if *req.ExpiresAt != "" {
+			t, err := time.Parse(time.RFC3339, *req.ExpiresAt)
+			if err != nil {
+				return nil, errors.New("invalid expires_at: use RFC 3339")
+			}
+			expiresAt = &t
+		}
+		set("expires_at", expiresAt)
+	}
+	if req.MaxClicks != nil {
+		if *req.MaxClicks < 0 {
+			return nil, errors.New("max_clicks must not be negative")
+		}
+		var maxClicks *int
+		if *req.MaxClicks > 0 {
+			maxClicks = req.MaxClicks
+		}
+		set("max_clicks", maxClicks)
+	}
+	if req.Password != nil {
+		var hash *string
+		if *req.Password != "" {
+			h, err := HashLinkPassword(*req.Password)
+			if err != nil {
+				return nil, err
+			}
+			hash = &h
+		}
+		set("password_hash", hash)
+	}
+	sets = append(sets, "revision=revision+1", "updated_at=NOW()")
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	link := &models.Link{}
+	err = tx.QueryRow(ctx,
+		`UPDATE links SET `+strings.Join(sets, ", ")+` WHERE id=$1 AND user_id=$2 AND deleted_at IS NULL
+		 RETURNING id, short_code, original_url, COALESCE(title, ''), user_id, is_active, expires_at, max_clicks, revision,
+		           COALESCE(password_hash, '') <> '', single_use, consumed_at, created_at, updated_at`,
+		args...,
+	).Scan(&link.ID, &link.ShortCode, &link.OriginalURL, &link.Title, &link.UserID,
+		&link.IsActive, &link.ExpiresAt, &link.MaxClicks, &link.Revision, &link.HasPassword,
+		&link.SingleUse, &link.ConsumedAt, &link.CreatedAt, &link.UpdatedAt)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, fmt.Errorf("update link: %w", err)
+	}
+	if err := s.recordRevision(ctx, tx, link.ID, userID); err != nil {
+		return nil, err
+	}
+
+	if req.Tags != nil {
+		if _, err := tx.Exec(ctx, "DELETE FROM link_tags WHERE link_id=$1", link.ID); err != nil {
+			return nil, err
+		}
+		s.attachTags(ctx, tx, link.ID, userID, req.Tags)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	link.ShortURL = fmt.Sprintf("%s/%s", s.cfg.BaseURL, link.ShortCode)
+	link.Tags, _ = s.linkTags(ctx, link.ID)
+	s.cacheLink(ctx, link)
+
+	return link, nil
+}
+
+// AddTags attaches tags to an existing link, creating any that don't exist yet.
+func (s *LinkService) AddTags(ctx context.Context, linkID, userID int, names []string) ([]models.Tag, error) {
+	if !s.Owns(ctx, linkID, userID) {
+		return nil, ErrNotFound
+	}
+	s.attachTags(ctx, s.db, linkID, userID, names)
+	return s.linkTags(ctx, linkID)
+}
+
+// RemoveTag detaches a tag from a link. The tag itself is kept.
+func (s *LinkService) RemoveTag(ctx context.Context, linkID, userID, tagID int) ([]models.Tag, error) {
+	res, err := s.db.Exec(ctx,
+		`DELETE FROM link_tags lt USING links l
+		 WHERE lt.link_id = l.id AND lt.link_id=$1 AND lt.tag_id=$2 AND l.user_id=$3`,
+		linkID, tagID, userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if res.RowsAffected() == 0 {
+		return nil, ErrNotFound
+	}
+	return s.linkTags(ctx, linkID)
+}
+
+func (s *LinkService) linkTags(ctx context.Context, linkID int) ([]models.Tag, error) {
+	links := []models.Link{{ID: linkID}}
+	if err := s.loadTags(ctx, links); err != nil {
+		return nil, err
+	}
+	if links[0].Tags == nil {
+		return []models.Tag{}, nil
+	}
+	return links[0].Tags, nil
+}
+
+// loadTags fills in Tags on every link with a single query.
+func (s *LinkService) loadTags(ctx context.Context, links []models.Link) error {
+	if len(links) == 0 {
+		return nil
+	}
+	ids := make([]int, len(links))
+	index := make(map[int]int, len(links))
+	for i, l := range links {
+		ids[i] = l.ID
+		index[l.ID] = i
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT lt.link_id, t.id, t.name FROM link_tags lt JOIN tags t ON t.id = lt.tag_id
+		 WHERE lt.link_id = ANY($1) ORDER BY t.name`,
+		ids,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var linkID int
+		var t models.Tag
+		if err := rows.Scan(&linkID, &t.ID, &t.Name); err != nil {
+			return err
+		}
+		i := index[linkID]
+		links[i].Tags = append(links[i].Tags, t)
+	}
+	return rows.Err()
+}
+
+func (s *LinkService) Owns(ctx context.Context, linkID, userID int) bool {
+	var owned bool
+	s.db.QueryRow(ctx, "SELECT EXISTS(SELECT 1 FROM links WHERE id=$1 AND user_id=$2 AND deleted_at IS NULL)", linkID, userID).Scan(&owned)
+	return owned
+}
+
+// History lists every revision of a link, newest first.
+func (s *LinkService) History(ctx context.Context, linkID, userID int) ([]models.LinkRevision, error) {
+	if !s.Owns(ctx, linkID, userID) {
+		return nil, ErrNotFound
+	}
+
+	rows, err := s.db.Query(ctx,
+		`SELECT revision, original_url, COALESCE(title, ''), COALESCE(is_active, true), expires_at, max_clicks,
+		        COALESCE(has_password, false), changed_by, created_at
+		 FROM link_revisions WHERE link_id=$1 ORDER BY revision DESC`,
+		linkID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []models.LinkRevision{}
+	for rows.Next() {
+		var r models.LinkRevision
+		if err := rows.Scan(&r.Revision, &r.OriginalURL, &r.Title, &r.IsActive, &r.ExpiresAt, &r.MaxClicks,
+			&r.HasPassword, &r.ChangedBy, &r.CreatedAt); err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	return revisions, rows.Err()
+}
+
+// RestoreRevision rolls a link back to an earlier revision. The rollback is
+// itself an update, so it is recorded as a new revision. Passwords are not
+// stored in revisions and are left as they are.
+func (s *LinkService) RestoreRevision(ctx context.Context, linkID, userID, revision int) (*models.Link, error) {
+	var r models.LinkRevision
+	err := s.db.QueryRow(ctx,
+		`SELECT r.original_url, COALESCE(r.title, ''), COALESCE(r.is_active, true), r.expires_at, r.max_clicks
+		 FROM link_revisions r JOIN links l ON l.id = r.link_id
+		 WHERE r.link_id=$1 AND l.user_id=$2 AND l.deleted_at IS NULL AND r.revision=$3`,
+		linkID, userID, revision,
+	).Scan(&r.OriginalURL, &r.Title, &r.IsActive, &r.ExpiresAt, &r.MaxClicks)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		return nil, err
+	}
+
+	expiresAt := ""
+	if r.ExpiresAt != nil {
+		expiresAt = r.ExpiresAt.Format(time.RFC3339)
+	}
+	maxClicks := 0
+	if r.MaxClicks != nil {
+		maxClicks = *r.MaxClicks
+	}
+
+	return s.Update(ctx, linkID, userID, models.UpdateLinkRequest{
+		URL:       &r.OriginalURL,
+		Title:     &r.Title,
+		IsActive:  &r.IsActive,
+		ExpiresAt: &expiresAt,
+		MaxClicks: &maxClicks,
+	})
+}
+
+// recordRevision snapshots the link's current state into link_revisions.
+func (s *LinkService) recordRevision(ctx context.Context, q querier, linkID, changedBy int) error {
+	return s.recordRevisions(ctx, q, []int{linkID}, changedBy)
+}
+
+// recordRevisions snapshots several links with one statement.
+func (s *LinkService) recordRevisions(ctx context.Context, q querier, linkIDs []int, changedBy int) error {
+	_, err := q.Exec(ctx,
+		`INSERT INTO link_revisions (link_id, revision, original_url, title, is_active, expires_at, max_clicks, has_password, changed_by)
+		 SELECT id, revision, original_url, title, is_active, expires_at, max_clicks, COALESCE(password_hash, '') <> '', $2
+		 FROM links WHERE id = ANY($1)`,
+		linkIDs, changedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("record revision: %w", err)
 	}
 	return nil
 }
+
+// attachTags upserts the user's tags by name and links them to linkID.
+func (s *LinkService) attachTags(ctx context.Context, q querier, linkID, userID int, names []string) {
+	for _, tagName := range names {
+		tagName, err := normalizeTagName(tagName)
+		if err != nil {
+			continue
+		}
+		var tagID int
+		err = q.QueryRow(ctx,
+			`INSERT INTO tags (name, user_id) VALUES ($1, $2) ON CONFLICT (name, user_id) DO UPDATE SET name=$1 RETURNING id`,
+			tagName, userID,
+		).Scan(&tagID)
+		if err != nil {
+			continue
+		}
+		q.Exec(ctx, "INSERT INTO link_tags (link_id, tag_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", linkID, tagID)
+	}
+}
+
+// Export streams every live link of the user to fn, oldest first, without
+// paging. It stops at the first error fn returns.
+func (s *LinkService) Export(ctx context.Context, userID int, fn func(models.LinkRecord) error) error {
+	rows, err := s.db.Query(ctx,
+		`SELECT l.short_code, l.original_url, COALESCE(l.title, ''),
+		        ARRAY(SELECT t.name FROM link_tags lt JOIN tags t ON t.id = lt.tag_id WHERE lt.link_id = l.id ORDER BY t.name),
+		        l.is_active, l.expires_at, l.max_clicks, `+clickCountExpr+`, l.created_at
+		 FROM links l WHERE l.user_id=$1 AND l.deleted_at IS NULL ORDER BY l.created_at, l.id`,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var r models.LinkRecord
+		if err := rows.Scan(&r.ShortCode, &r.URL, &r.Title, &r.Tags, &r.IsActive,
+			&r.ExpiresAt, &r.MaxClicks, &r.ClickCount, &r.CreatedAt); err != nil {
+			return err
+		}
+		if err := fn(r); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+// Import creates a link from an exported record through the same
+// validation as Create. Inactive records are stored inactive, so they never
+// resolve and get a single revision.
+func (s *LinkService) Import(ctx context.Context, userID int, r models.LinkRecord) (*models.Link, error) {
+	return s.create(ctx, userID, models.CreateLinkRequest{
+		URL:        r.URL,
+		Title:      r.Title,
+		CustomCode: r.ShortCode,
+		ExpiresAt:  r.ExpiresAt,
+		MaxClicks:  r.MaxClicks,
+		Tags:       r.Tags,
+	}, createOptions{inactive: !r.IsActive})
+}
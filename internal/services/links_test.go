@@ -0,0 +1,43 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shortly/internal/models"
+)
+
+func TestLimitReached(t *testing.T) {
+	limit := func(n int) *int { return &n }
+	tests := []struct {
+		count     int
+		maxClicks *int
+		want      bool
+	}{
+		{0, nil, false},
+		{1000, nil, false},
+		{4, limit(5), false},
+		{5, limit(5), true}, // exhausted: bots get the limit response too
+		{6, limit(5), true},
+		{0, limit(0), true},
+	}
+	for _, tt := range tests {
+		if got := limitReached(tt.count, tt.maxClicks); got != tt.want {
+			t.Errorf("limitReached(%d, %v) = %v, want %v", tt.count, tt.maxClicks, got, tt.want)
+		}
+	}
+}
+
+func TestCheckLinkClickLimit(t *testing.T) {
+	limit := 3
+	link := &models.Link{IsActive: true, MaxClicks: &limit, ClickCount: 3}
+	if err := checkLink(link); err != ErrClickLimit {
+		t.Errorf("checkLink(exhausted) = %v, want ErrClickLimit", err)
+	}
+	link.ClickCount = 2
+	future := time.Now().Add(time.Hour)
+	link.ExpiresAt = &future
+	if err := checkLink(link); err != nil {
+		t.Errorf("checkLink(under limit) = %v, want nil", err)
+	}
+}
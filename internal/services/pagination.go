@@ -0,0 +1,69 @@
+package services
+
+import (
+	"fmt"
+
+	"github.com/shortly/internal/utils"
+)
+
+// keyset describes a (sort key, id) keyset query. expr and idExpr are SQL
+// expressions; desc is the display order.
+type keyset struct {
+	expr   string
+	idExpr string
+	desc   bool
+	cursor *utils.Cursor
+}
+
+// where returns the condition selecting rows past the cursor, or "" on the
+// first page. value is the cursor's sort key, already converted to its Go type.
+func (k keyset) where(arg func(interface{}) string, value interface{}) string {
+	if k.cursor == nil {
+		return ""
+	}
+	op := ">"
+	if k.desc != k.cursor.Prev {
+		op = "<"
+	}
+	return fmt.Sprintf("(%s, %s) %s (%s, %s)", k.expr, k.idExpr, op, arg(value), arg(k.cursor.ID))
+}
+
+// orderBy returns the ORDER BY clause for fetching; backwards pages are
+// fetched in reverse and flipped back by keysetPage.
+func (k keyset) orderBy() string {
+	dir := "ASC"
+	if k.desc != (k.cursor != nil && k.cursor.Prev) {
+		dir = "DESC"
+	}
+	return fmt.Sprintf("%s %s, %s %s", k.expr, dir, k.idExpr, dir)
+}
+
+// keysetPage trims the limit+1 rows fetched for a page, restores display
+// order and derives the next/prev cursors from the boundary rows.
+func keysetPage[T any](rows []T, limit int, cur *utils.Cursor, boundary func(T) utils.Cursor) ([]T, string, string) {
+	backward := cur != nil && cur.Prev
+	more := len(rows) > limit
+	if more {
+		rows = rows[:limit]
+	}
+	if len(rows) == 0 {
+		return rows, "", ""
+	}
+	if backward {
+		for i, j := 0, len(rows)-1; i < j; i, j = i+1, j-1 {
+			rows[i], rows[j] = rows[j], rows[i]
+		}
+	}
+
+	first, last := boundary(rows[0]), boundary(rows[len(rows)-1])
+	first.Prev = true
+
+	var next, prev string
+	if !backward && more || backward {
+		next = utils.EncodeCursor(last)
+	}
+	if backward && more || !backward && cur != nil {
+		prev = utils.EncodeCursor(first)
+	}
+	return rows, next, prev
+}
@@ -1,6 +1,22 @@
 package services
 
-import "golang.org/x/crypto/bcrypt"
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/shortly/internal/models"
+)
+
+// UnlockTTL is how long a successful password unlock is remembered.
+const UnlockTTL = 30 * time.Minute
 
 func HashLinkPassword(password string) (string, error) {
 	hash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
@@ -10,3 +26,41 @@ func HashLinkPassword(password string) (string, error) {
 func VerifyLinkPassword(password, hash string) bool {
 	return bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)) == nil
 }
+
+// VerifyPassword checks password against the link's stored hash.
+func (s *LinkService) VerifyPassword(ctx context.Context, linkID int, password string) bool {
+	var hash string
+	err := s.db.QueryRow(ctx, "SELECT COALESCE(password_hash, '') FROM links WHERE id=$1", linkID).Scan(&hash)
+	if err != nil || hash == "" {
+		return false
+	}
+	return VerifyLinkPassword(password, hash)
+}
+
+// UnlockToken returns a signed token proving the link was unlocked. It is
+// bound to the link's current revision, so editing the link (including its
+// password) invalidates outstanding tokens.
+func (s *LinkService) UnlockToken(link *models.Link) string {
+	exp := strconv.FormatInt(time.Now().Add(UnlockTTL).Unix(), 10)
+	return exp + "." + s.signUnlock(link, exp)
+}
+
+// ValidUnlockToken reports whether token was issued by UnlockToken for this
+// link revision and has not expired.
+func (s *LinkService) ValidUnlockToken(link *models.Link, token string) bool {
+	exp, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	unix, err := strconv.ParseInt(exp, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(s.signUnlock(link, exp)))
+}
+
+func (s *LinkService) signUnlock(link *models.Link, exp string) string {
+	mac := hmac.New(sha256.New, []byte(s.cfg.JWTSecret))
+	fmt.Fprintf(mac, "unlock|%d|%s|%d|%s", link.ID, link.ShortCode, link.Revision, exp)
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
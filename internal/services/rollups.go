@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// how many click ids one rollup transaction folds in
+	rollupChunkSize = 50000
+	// how long an observed high-water mark waits before it is folded in.
+	// Click writes time out after 30s, so by then every transaction that
+	// took an id below the mark has committed or rolled back; folding in
+	// earlier could skip a click that becomes visible later.
+	rollupSettle = time.Minute
+)
+
+// clickDimensions unpivots a click row c into one (dimension, value) row
+// per rollup dimension. 'total' counts every click once. Bot clicks get
+// the same rows under bot:-prefixed dimensions so stats can leave them out.
+const clickDimensions = `(
+		SELECT CASE WHEN c.is_bot THEN 'bot:' || v.dimension ELSE v.dimension END AS dimension, v.value
+		FROM (VALUES
+			('total', ''),
+			('country', COALESCE(c.country, '')),
+			('referer', COALESCE(c.referer, '')),
+			('browser', COALESCE(c.browser, '')),
+			('device', COALESCE(c.device, '')),
+			('os', COALESCE(c.os, ''))
+		) AS v(dimension, value)
+	) AS d`
+
+// rollupInsert folds clicks with ids in ($1, $2] into a rollup table.
+func rollupInsert(table, unit string) string {
+	return fmt.Sprintf(`INSERT INTO %[1]s (link_id, dimension, bucket, value, clicks)
+		SELECT c.link_id, d.dimension, date_trunc('%[2]s', c.created_at, 'UTC'), d.value, COUNT(*)
+		FROM clicks c CROSS JOIN LATERAL %[3]s
+		WHERE c.id > $1 AND c.id <= $2
+		GROUP BY 1, 2, 3, 4
+		ON CONFLICT (link_id, dimension, bucket, value) DO UPDATE SET clicks = %[1]s.clicks + EXCLUDED.clicks`,
+		table, unit, clickDimensions)
+}
+
+// RunRollups keeps the rollup tables current until ctx is cancelled.
+func (s *ClickService) RunRollups(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Rollup(ctx); err != nil && ctx.Err() == nil {
+				log.Println("click rollup:", err)
+			}
+		}
+	}
+}
+
+// Rollup folds settled clicks into the hourly and daily rollups and returns
+// how many click ids it moved the watermark by.
+func (s *ClickService) Rollup(ctx context.Context) (int64, error) {
+	var total int64
+	for {
+		n, more, err := s.rollupChunk(ctx)
+		total += n
+		if err != nil || !more {
+			return total, err
+		}
+	}
+}
+
+// rollupChunk does one step under the state row lock: fold in up to
+// rollupChunkSize ids of a settled mark, or observe a new mark once caught
+// up. Instances that find the row locked leave the work to its holder.
+func (s *ClickService) rollupChunk(ctx context.Context) (advanced int64, more bool, err error) {
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return 0, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var last, pending int64
+	var settled bool
+	err = tx.QueryRow(ctx,
+		`SELECT last_click_id, pending_click_id, observed_at <= NOW() - $1::interval
+		 FROM click_rollup_state FOR UPDATE SKIP LOCKED`, rollupSettle,
+	).Scan(&last, &pending, &settled)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, false, nil
+	}
+	if err != nil {
+		return 0, false, err
+	}
+	if !settled {
+		return 0, false, nil
+	}
+
+	if last >= pending {
+		_, err := tx.Exec(ctx,
+			"UPDATE click_rollup_state SET pending_click_id = (SELECT COALESCE(MAX(id), 0) FROM clicks), observed_at = NOW()",
+		)
+		if err != nil {
+			return 0, false, err
+		}
+		return 0, false, tx.Commit(ctx)
+	}
+
+	hi := min(pending, last+rollupChunkSize)
+	for _, table := range []struct{ name, unit string }{
+		{"click_rollups_hourly", "hour"},
+		{"click_rollups_daily", "day"},
+	} {
+		if _, err := tx.Exec(ctx, rollupInsert(table.name, table.unit), last, hi); err != nil {
+			return 0, false, fmt.Errorf("%s: %w", table.name, err)
+		}
+	}
+	if _, err := tx.Exec(ctx, "UPDATE click_rollup_state SET last_click_id = $1", hi); err != nil {
+		return 0, false, err
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return 0, false, err
+	}
+	return hi - last, hi < pending, nil
+}
+
+// rawSince selects unpivoted clicks the rollups don't cover yet, as
+// (link_id, at, dimension, value, clicks) rows. cond filters the clicks c.
+func rawSince(cond string) string {
+	return `SELECT c.link_id, c.created_at AS at, d.dimension, d.value, 1 AS clicks
+		FROM clicks c CROSS JOIN LATERAL ` + clickDimensions + `
+		WHERE ` + cond + ` AND c.id > (SELECT last_click_id FROM click_rollup_state)`
+}
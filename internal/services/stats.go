@@ -0,0 +1,388 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/shortly/internal/models"
+)
+
+// maxStatsBuckets bounds the series length of one stats request.
+const maxStatsBuckets = 2000
+
+// topLimit is the longest breakdown list any stats response carries.
+const topLimit = 10
+
+var statsIntervals = map[string]bool{"hour": true, "day": true, "week": true, "month": true}
+
+// ValidateStatsQuery rejects unknown intervals, empty windows and series
+// longer than maxStatsBuckets.
+func ValidateStatsQuery(q models.StatsQuery) error {
+	if !statsIntervals[q.Interval] {
+		return errors.New("interval must be one of hour, day, week, month")
+	}
+	if !q.From.Before(q.To) {
+		return errors.New("from must be before to")
+	}
+	if n := len(statsBuckets(q)); n > maxStatsBuckets {
+		return fmt.Errorf("too many buckets (%d), use a shorter range or a longer interval", n)
+	}
+	return nil
+}
+
+// GetStats returns the totals, breakdowns and time series of one of the
+// user's links.
+func (s *ClickService) GetStats(ctx context.Context, linkID, userID int, q models.StatsQuery) (*models.ClickStats, error) {
+	ids, err := s.ownedLinks(ctx,
+		"SELECT id FROM links WHERE id=$1 AND user_id=$2 AND deleted_at IS NULL", linkID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrNotFound
+	}
+	return s.statsFor(ctx, ids, q)
+}
+
+// statsFor aggregates clicks over a set of links. Rollups cover whatever
+// whole hours and days of the window they can and raw clicks fill in the
+// rest, see splitSpans.
+func (s *ClickService) statsFor(ctx context.Context, linkIDs []int, q models.StatsQuery) (*models.ClickStats, error) {
+	stats := &models.ClickStats{
+		From:        q.From.In(q.Location),
+		To:          q.To.In(q.Location),
+		Timezone:    q.Location.String(),
+		Interval:    q.Interval,
+		IncludeBots: q.IncludeBots,
+	}
+
+	top, total, err := s.breakdowns(ctx, linkIDs, q)
+	if err != nil {
+		return nil, err
+	}
+	stats.TotalClicks = total
+	stats.TopReferrers = firstN(top["referer"], 10)
+	stats.TopCountries = firstN(top["country"], 10)
+	stats.TopBrowsers = firstN(top["browser"], 5)
+	stats.TopDevices = firstN(top["device"], 5)
+	stats.TopOS = firstN(top["os"], 5)
+
+	stats.UniqueClicks, err = s.uniqueClicks(ctx, linkIDs, q)
+	if err == nil && !q.Bounded {
+		// imported totals have no timestamps, so only all-time stats carry them
+		err = s.db.QueryRow(ctx,
+			"SELECT COALESCE(SUM(imported_clicks), 0) FROM links WHERE id = ANY($1)", linkIDs,
+		).Scan(&stats.ImportedClicks)
+		stats.TotalClicks += stats.ImportedClicks
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if stats.Series, err = s.series(ctx, linkIDs, q); err != nil {
+		return nil, err
+	}
+	if q.Compare {
+		if stats.Comparison, err = s.compare(ctx, linkIDs, q, stats); err != nil {
+			return nil, err
+		}
+	}
+	if q.Interval == "day" {
+		stats.ClicksByDay = make([]models.DayCount, len(stats.Series))
+		for i, p := range stats.Series {
+			stats.ClicksByDay[i] = models.DayCount{Date: p.Time.Format("2006-01-02"), Count: p.Count}
+		}
+	}
+	return stats, nil
+}
+
+// uniqueClicks counts distinct visitor ips in the window, or all time.
+// Distinct counts don't add up across buckets, so unlike everything else in
+// the stats this scans the raw clicks rather than the rollups.
+func (s *ClickService) uniqueClicks(ctx context.Context, linkIDs []int, q models.StatsQuery) (int, error) {
+	query := "SELECT COUNT(DISTINCT ip_address) FROM clicks WHERE link_id = ANY($1)"
+	args := []interface{}{linkIDs}
+	if !q.IncludeBots {
+		query += " AND NOT is_bot"
+	}
+	if q.Bounded {
+		query += " AND created_at >= $2 AND created_at < $3"
+		args = append(args, q.From, q.To)
+	}
+	var n int
+	err := s.db.QueryRow(ctx, query, args...).Scan(&n)
+	return n, err
+}
+
+// breakdowns returns the top values of every dimension and the total, in
+// one statement so all of it sees the same rollup watermark.
+func (s *ClickService) breakdowns(ctx context.Context, linkIDs []int, q models.StatsQuery) (map[string][]models.NameCount, int, error) {
+	args := []interface{}{linkIDs}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	rows, err := s.db.Query(ctx,
+		`WITH src AS (`+statsSource(q, false, arg)+`)
+		SELECT dimension, value, clicks FROM (
+			SELECT dimension, value, SUM(clicks) AS clicks,
+			       ROW_NUMBER() OVER (PARTITION BY dimension ORDER BY SUM(clicks) DESC, value) AS rn
+			FROM src GROUP BY dimension, value
+		) t WHERE rn <= `+arg(topLimit)+` ORDER BY dimension, rn`,
+		args...,
+	)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	top := make(map[string][]models.NameCount)
+	total := 0
+	for rows.Next() {
+		var dimension string
+		var nc models.NameCount
+		if err := rows.Scan(&dimension, &nc.Name, &nc.Count); err != nil {
+			return nil, 0, err
+		}
+		if dimension == "total" {
+			total = nc.Count
+			continue
+		}
+		nc.Name = displayValue(nc.Name)
+		top[dimension] = append(top[dimension], nc)
+	}
+	return top, total, rows.Err()
+}
+
+// series counts clicks per bucket, with empty buckets filled in.
+func (s *ClickService) series(ctx context.Context, linkIDs []int, q models.StatsQuery) ([]models.TimeCount, error) {
+	args := []interface{}{linkIDs}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	// hourly rollups can only be re-bucketed into zones that are a whole
+	// number of hours off UTC; anything else counts raw clicks
+	var sp spans
+	if wholeHourOffset(q.From, q.Location) && wholeHourOffset(q.To, q.Location) {
+		sp = splitSpans(q.From, q.To, false)
+	} else {
+		sp = spans{rawHead: span{q.From, q.To}}
+	}
+
+	rows, err := s.db.Query(ctx,
+		`WITH src AS (`+clickSource(sp, sourceFilter(true, q.IncludeBots), q.IncludeBots, arg)+`)
+		SELECT date_trunc(`+arg(q.Interval)+`, at, `+arg(q.Location.String())+`) AS bucket, SUM(clicks)
+		FROM src GROUP BY bucket`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int)
+	for rows.Next() {
+		var bucket time.Time
+		var n int
+		if err := rows.Scan(&bucket, &n); err != nil {
+			return nil, err
+		}
+		counts[bucket.Unix()] = n
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	buckets := statsBuckets(q)
+	series := make([]models.TimeCount, len(buckets))
+	for i, b := range buckets {
+		series[i] = models.TimeCount{Time: b, Count: counts[b.Unix()]}
+	}
+	return series, nil
+}
+
+func firstN(list []models.NameCount, n int) []models.NameCount {
+	if len(list) > n {
+		return list[:n]
+	}
+	return list
+}
+
+type span struct{ from, to time.Time }
+
+func (s span) empty() bool {
+	return !s.from.Before(s.to)
+}
+
+// spans covers a window with the cheapest exact sources: whole UTC days
+// from the daily rollups, whole hours next to them from the hourly rollups
+// and the sub-hour edges from raw clicks.
+type spans struct {
+	rawHead, hourHead, days, hourTail, rawTail span
+}
+
+// splitSpans splits [from, to) into spans. Without daily, whole days are
+// served from the hourly rollups too.
+func splitSpans(from, to time.Time, daily bool) spans {
+	from, to = from.UTC(), to.UTC()
+	h1, h2 := ceilTime(from, time.Hour), to.Truncate(time.Hour)
+	if !h1.Before(h2) {
+		return spans{rawHead: span{from, to}}
+	}
+	sp := spans{rawHead: span{from, h1}, rawTail: span{h2, to}}
+	d1, d2 := ceilTime(h1, 24*time.Hour), h2.Truncate(24*time.Hour)
+	if !daily || !d1.Before(d2) {
+		sp.hourHead = span{h1, h2}
+		return sp
+	}
+	sp.hourHead, sp.days, sp.hourTail = span{h1, d1}, span{d1, d2}, span{d2, h2}
+	return sp
+}
+
+// covered is the part of the window the rollups serve.
+func (sp spans) covered() span {
+	if sp.hourHead.empty() {
+		return span{}
+	}
+	if !sp.hourTail.empty() {
+		return span{sp.hourHead.from, sp.hourTail.to}
+	}
+	return sp.hourHead
+}
+
+// ceilTime rounds t up to a multiple of d; time.Truncate rounds against
+// the zero time, which is UTC midnight.
+func ceilTime(t time.Time, d time.Duration) time.Time {
+	if f := t.Truncate(d); f.Before(t) {
+		return f.Add(d)
+	}
+	return t
+}
+
+// statsSource selects the clicks q's totals and breakdowns count: the
+// window when bounded, everything otherwise.
+func statsSource(q models.StatsQuery, totalOnly bool, arg func(interface{}) string) string {
+	filter := sourceFilter(totalOnly, q.IncludeBots)
+	if q.Bounded {
+		return clickSource(splitSpans(q.From, q.To, true), filter, q.IncludeBots, arg)
+	}
+	return withBots(`SELECT link_id, bucket AS at, dimension, value, clicks FROM click_rollups_daily WHERE link_id = ANY($1)`+filter+`
+		UNION ALL SELECT * FROM (`+rawSince("c.link_id = ANY($1)")+`) fresh WHERE true`+filter, q.IncludeBots)
+}
+
+// sourceFilter narrows source rows by dimension. Bot clicks are kept
+// under bot: dimensions (see clickDimensions), so leaving them out is a
+// filter too.
+func sourceFilter(totalOnly, includeBots bool) string {
+	switch {
+	case totalOnly && includeBots:
+		return " AND dimension IN ('total', 'bot:total')"
+	case totalOnly:
+		return " AND dimension = 'total'"
+	case includeBots:
+		return ""
+	default:
+		return " AND dimension NOT LIKE 'bot:%'"
+	}
+}
+
+// withBots counts bot clicks under the plain dimensions when they are
+// included.
+func withBots(src string, includeBots bool) string {
+	if !includeBots {
+		return src
+	}
+	return `SELECT link_id, at, CASE WHEN dimension LIKE 'bot:%' THEN substr(dimension, 5) ELSE dimension END AS dimension, value, clicks
+		FROM (` + src + `) s`
+}
+
+// clickSource builds a union of (link_id, at, dimension, value, clicks) rows for the
+// links in $1 over sp, narrowed by filter. Rollup spans are topped up with
+// raw clicks past the watermark; raw edge spans read every click.
+func clickSource(sp spans, filter string, includeBots bool, arg func(interface{}) string) string {
+	var parts []string
+	rollup := func(table string, s span) {
+		if !s.empty() {
+			parts = append(parts, `SELECT link_id, bucket AS at, dimension, value, clicks FROM `+table+`
+				WHERE link_id = ANY($1) AND bucket >= `+arg(s.from)+` AND bucket < `+arg(s.to)+filter)
+		}
+	}
+	raw := func(s span) {
+		if !s.empty() {
+			parts = append(parts, `SELECT link_id, at, dimension, value, clicks FROM (
+				SELECT c.link_id, c.created_at AS at, d.dimension, d.value, 1 AS clicks
+				FROM clicks c CROSS JOIN LATERAL `+clickDimensions+`
+				WHERE c.link_id = ANY($1) AND c.created_at >= `+arg(s.from)+` AND c.created_at < `+arg(s.to)+`
+			) raw WHERE true`+filter)
+		}
+	}
+
+	raw(sp.rawHead)
+	rollup("click_rollups_hourly", sp.hourHead)
+	rollup("click_rollups_daily", sp.days)
+	rollup("click_rollups_hourly", sp.hourTail)
+	raw(sp.rawTail)
+	if c := sp.covered(); !c.empty() {
+		parts = append(parts, `SELECT link_id, at, dimension, value, clicks FROM (`+
+			rawSince("c.link_id = ANY($1) AND c.created_at >= "+arg(c.from)+" AND c.created_at < "+arg(c.to))+
+			`) fresh WHERE true`+filter)
+	}
+	if len(parts) == 0 {
+		return `SELECT NULL::int AS link_id, NULL::timestamptz AS at, NULL::text AS dimension, NULL::text AS value, 0 AS clicks WHERE false`
+	}
+	return withBots(strings.Join(parts, "\n\t\tUNION ALL\n\t\t"), includeBots)
+}
+
+func wholeHourOffset(t time.Time, loc *time.Location) bool {
+	_, offset := t.In(loc).Zone()
+	return offset%3600 == 0
+}
+
+// bucketStart truncates t to the start of its interval in loc, matching
+// Postgres date_trunc with a time zone (weeks start on Monday).
+func bucketStart(t time.Time, interval string, loc *time.Location) time.Time {
+	t = t.In(loc)
+	y, m, d := t.Date()
+	switch interval {
+	case "hour":
+		return time.Date(y, m, d, t.Hour(), 0, 0, 0, loc)
+	case "week":
+		return time.Date(y, m, d-(int(t.Weekday())+6)%7, 0, 0, 0, 0, loc)
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, loc)
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, loc)
+	}
+}
+
+func nextBucket(t time.Time, interval string) time.Time {
+	switch interval {
+	case "hour":
+		return bucketStart(t.Add(time.Hour), interval, t.Location())
+	case "week":
+		return t.AddDate(0, 0, 7)
+	case "month":
+		return t.AddDate(0, 1, 0)
+	default:
+		return t.AddDate(0, 0, 1)
+	}
+}
+
+// statsBuckets lists the start of every bucket that overlaps [From, To).
+// It stops early past maxStatsBuckets so validation stays cheap.
+func statsBuckets(q models.StatsQuery) []time.Time {
+	var buckets []time.Time
+	for b := bucketStart(q.From, q.Interval, q.Location); b.Before(q.To); b = nextBucket(b, q.Interval) {
+		buckets = append(buckets, b)
+		if len(buckets) > maxStatsBuckets {
+			break
+		}
+	}
+	return buckets
+}
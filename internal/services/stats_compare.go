@@ -0,0 +1,165 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/shortly/internal/models"
+)
+
+// compare computes the comparison window's figures for stats. Breakdowns
+// are looked up for the current top values only, so a value that was big
+// before but has dropped out of the top lists is not reported.
+func (s *ClickService) compare(ctx context.Context, linkIDs []int, q models.StatsQuery, stats *models.ClickStats) (*models.StatsComparison, error) {
+	prev := q
+	prev.From, prev.To = comparisonWindow(q)
+
+	lists := map[string][]models.NameCount{
+		"referer": stats.TopReferrers,
+		"country": stats.TopCountries,
+		"browser": stats.TopBrowsers,
+		"device":  stats.TopDevices,
+		"os":      stats.TopOS,
+	}
+	dims, values := []string{"total"}, []string{""}
+	for dim, list := range lists {
+		for _, nc := range list {
+			dims = append(dims, dim)
+			values = append(values, rawValue(nc.Name))
+		}
+	}
+	counts, err := s.valueCounts(ctx, linkIDs, prev, dims, values)
+	if err != nil {
+		return nil, err
+	}
+
+	unique, err := s.uniqueClicks(ctx, linkIDs, prev)
+	if err != nil {
+		return nil, err
+	}
+	series, err := s.series(ctx, linkIDs, prev)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := func(dim string) []models.NameChange {
+		list := lists[dim]
+		out := make([]models.NameChange, len(list))
+		for i, nc := range list {
+			out[i] = models.NameChange{
+				Name:   nc.Name,
+				Change: change(nc.Count, counts[dim][rawValue(nc.Name)]),
+			}
+		}
+		return out
+	}
+	return &models.StatsComparison{
+		From: prev.From.In(q.Location),
+		To:   prev.To.In(q.Location),
+		// imported clicks are all-time only, so compare tracked clicks
+		TotalClicks:  change(stats.TotalClicks-stats.ImportedClicks, counts["total"][""]),
+		UniqueClicks: change(stats.UniqueClicks, unique),
+		Series:       series,
+		TopReferrers: changes("referer"),
+		TopCountries: changes("country"),
+		TopBrowsers:  changes("browser"),
+		TopDevices:   changes("device"),
+		TopOS:        changes("os"),
+	}, nil
+}
+
+// valueCounts counts the clicks of each (dims[i], values[i]) pair in the
+// window.
+func (s *ClickService) valueCounts(ctx context.Context, linkIDs []int, q models.StatsQuery, dims, values []string) (map[string]map[string]int, error) {
+	args := []interface{}{linkIDs}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	rows, err := s.db.Query(ctx,
+		`WITH src AS (`+statsSource(q, false, arg)+`)
+		SELECT dimension, value, SUM(clicks) FROM src
+		WHERE (dimension, value) IN (SELECT * FROM unnest(`+arg(dims)+`::text[], `+arg(values)+`::text[]))
+		GROUP BY dimension, value`,
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[string]map[string]int)
+	for rows.Next() {
+		var dim, value string
+		var n int
+		if err := rows.Scan(&dim, &value, &n); err != nil {
+			return nil, err
+		}
+		if counts[dim] == nil {
+			counts[dim] = make(map[string]int)
+		}
+		counts[dim][value] = n
+	}
+	return counts, rows.Err()
+}
+
+// displayValue names the empty value of any breakdown dimension: no
+// referer, or a country, browser, device or os that couldn't be told.
+func displayValue(v string) string {
+	if v == "" {
+		return "direct"
+	}
+	return v
+}
+
+// rawValue undoes displayValue.
+func rawValue(name string) string {
+	if name == "direct" {
+		return ""
+	}
+	return name
+}
+
+// comparisonWindow returns the window q is compared against: as long as
+// q's and starting at CompareFrom, or ending where q starts. Windows of
+// whole local days are shifted by days, so they stay aligned across DST.
+func comparisonWindow(q models.StatsQuery) (time.Time, time.Time) {
+	shift := func(t time.Time, sign int) time.Time {
+		return t.Add(time.Duration(sign) * q.To.Sub(q.From))
+	}
+	if days, ok := wholeDays(q.From, q.To, q.Location); ok {
+		shift = func(t time.Time, sign int) time.Time {
+			return t.In(q.Location).AddDate(0, 0, sign*days)
+		}
+	}
+	if q.CompareFrom.IsZero() {
+		return shift(q.From, -1), q.From
+	}
+	return q.CompareFrom, shift(q.CompareFrom, 1)
+}
+
+// wholeDays reports how many days [from, to) spans if both ends are
+// midnight in loc.
+func wholeDays(from, to time.Time, loc *time.Location) (int, bool) {
+	from, to = from.In(loc), to.In(loc)
+	if from.Hour()|from.Minute()|from.Second()|from.Nanosecond() != 0 ||
+		to.Hour()|to.Minute()|to.Second()|to.Nanosecond() != 0 {
+		return 0, false
+	}
+	fy, fm, fd := from.Date()
+	ty, tm, td := to.Date()
+	days := time.Date(ty, tm, td, 0, 0, 0, 0, time.UTC).Sub(time.Date(fy, fm, fd, 0, 0, 0, 0, time.UTC)) / (24 * time.Hour)
+	return int(days), true
+}
+
+func change(current, previous int) models.Change {
+	c := models.Change{Current: current, Previous: previous, Delta: current - previous}
+	if previous > 0 {
+		pct := math.Round(float64(c.Delta)*1000/float64(previous)) / 10
+		c.PctChange = &pct
+	}
+	return c
+}
@@ -0,0 +1,110 @@
+package services
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5"
+
+	"github.com/shortly/internal/models"
+)
+
+// OverviewStats aggregates clicks over all of the user's live links.
+func (s *ClickService) OverviewStats(ctx context.Context, userID int, q models.StatsQuery) (*models.AggregateStats, error) {
+	ids, err := s.ownedLinks(ctx,
+		"SELECT id FROM links WHERE user_id=$1 AND deleted_at IS NULL", userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.aggregateStats(ctx, ids, q)
+}
+
+// TagStats aggregates clicks over the user's live links carrying a tag.
+func (s *ClickService) TagStats(ctx context.Context, tagID, userID int, q models.StatsQuery) (*models.AggregateStats, error) {
+	var owned bool
+	if err := s.db.QueryRow(ctx,
+		"SELECT EXISTS(SELECT 1 FROM tags WHERE id=$1 AND user_id=$2)", tagID, userID,
+	).Scan(&owned); err != nil {
+		return nil, err
+	}
+	if !owned {
+		return nil, ErrNotFound
+	}
+
+	ids, err := s.ownedLinks(ctx,
+		`SELECT l.id FROM links l JOIN link_tags lt ON lt.link_id = l.id
+		 WHERE lt.tag_id=$1 AND l.user_id=$2 AND l.deleted_at IS NULL`, tagID, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.aggregateStats(ctx, ids, q)
+}
+
+// ownedLinks runs a query selecting link ids. Every stats entry point goes
+// through it, so the ids handed to statsFor are always the caller's own.
+func (s *ClickService) ownedLinks(ctx context.Context, query string, args ...interface{}) ([]int, error) {
+	rows, err := s.db.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[int])
+	if err != nil {
+		return nil, err
+	}
+	if ids == nil {
+		ids = []int{}
+	}
+	return ids, nil
+}
+
+func (s *ClickService) aggregateStats(ctx context.Context, linkIDs []int, q models.StatsQuery) (*models.AggregateStats, error) {
+	stats, err := s.statsFor(ctx, linkIDs, q)
+	if err != nil {
+		return nil, err
+	}
+	top, err := s.topLinks(ctx, linkIDs, q)
+	if err != nil {
+		return nil, err
+	}
+	return &models.AggregateStats{ClickStats: *stats, Links: len(linkIDs), TopLinks: top}, nil
+}
+
+// topLinks returns the links with the most clicks in q's window, counting
+// imported clicks for all-time stats like TotalClicks does.
+func (s *ClickService) topLinks(ctx context.Context, linkIDs []int, q models.StatsQuery) ([]models.LinkCount, error) {
+	args := []interface{}{linkIDs}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	imported := ""
+	if !q.Bounded {
+		imported = " + l.imported_clicks"
+	}
+
+	rows, err := s.db.Query(ctx,
+		`WITH src AS (`+statsSource(q, true, arg)+`),
+		per_link AS (SELECT link_id, SUM(clicks) AS clicks FROM src GROUP BY link_id)
+		SELECT id, short_code, title, clicks FROM (
+			SELECT l.id, l.short_code, COALESCE(l.title, '') AS title, (COALESCE(p.clicks, 0)`+imported+`)::int AS clicks
+			FROM links l LEFT JOIN per_link p ON p.link_id = l.id
+			WHERE l.id = ANY($1)
+		) t WHERE clicks > 0 ORDER BY clicks DESC, id LIMIT `+arg(topLimit),
+		args...,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	top := []models.LinkCount{}
+	for rows.Next() {
+		var lc models.LinkCount
+		if err := rows.Scan(&lc.ID, &lc.ShortCode, &lc.Title, &lc.Clicks); err != nil {
+			return nil, err
+		}
+		lc.ShortURL = fmt.Sprintf("%s/%s", s.cfg.BaseURL, lc.ShortCode)
+		top = append(top, lc)
+	}
+	return top, rows.Err()
+}
@@ -0,0 +1,241 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/shortly/internal/models"
+)
+
+func mustTime(t *testing.T, v string) time.Time {
+	t.Helper()
+	ts, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ts
+}
+
+func TestSplitSpans(t *testing.T) {
+	from := mustTime(t, "2025-03-01T10:30:00Z")
+	to := mustTime(t, "2025-03-04T05:15:00Z")
+
+	sp := splitSpans(from, to, true)
+	want := spans{
+		rawHead:  span{from, mustTime(t, "2025-03-01T11:00:00Z")},
+		hourHead: span{mustTime(t, "2025-03-01T11:00:00Z"), mustTime(t, "2025-03-02T00:00:00Z")},
+		days:     span{mustTime(t, "2025-03-02T00:00:00Z"), mustTime(t, "2025-03-04T00:00:00Z")},
+		hourTail: span{mustTime(t, "2025-03-04T00:00:00Z"), mustTime(t, "2025-03-04T05:00:00Z")},
+		rawTail:  span{mustTime(t, "2025-03-04T05:00:00Z"), to},
+	}
+	if sp != want {
+		t.Fatalf("daily spans = %+v, want %+v", sp, want)
+	}
+	if c := sp.covered(); c != (span{want.hourHead.from, want.hourTail.to}) {
+		t.Errorf("covered = %+v", c)
+	}
+
+	sp = splitSpans(from, to, false)
+	if sp.hourHead != (span{want.hourHead.from, want.hourTail.to}) || !sp.days.empty() || !sp.hourTail.empty() {
+		t.Errorf("hourly spans = %+v", sp)
+	}
+}
+
+func TestSplitSpansWithinAnHour(t *testing.T) {
+	from := mustTime(t, "2025-03-01T10:10:00Z")
+	to := mustTime(t, "2025-03-01T10:50:00Z")
+	sp := splitSpans(from, to, true)
+	if sp != (spans{rawHead: span{from, to}}) {
+		t.Fatalf("spans = %+v", sp)
+	}
+	if !sp.covered().empty() {
+		t.Errorf("covered = %+v, want empty", sp.covered())
+	}
+}
+
+func TestSplitSpansAligned(t *testing.T) {
+	from := mustTime(t, "2025-03-01T00:00:00Z")
+	to := mustTime(t, "2025-03-03T00:00:00Z")
+	sp := splitSpans(from, to, true)
+	if !sp.rawHead.empty() || !sp.rawTail.empty() || !sp.hourHead.empty() || !sp.hourTail.empty() {
+		t.Errorf("expected days only, got %+v", sp)
+	}
+	if sp.days != (span{from, to}) {
+		t.Errorf("days = %+v", sp.days)
+	}
+}
+
+func TestStatsBuckets(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tz database:", err)
+	}
+
+	tests := []struct {
+		name  string
+		q     models.StatsQuery
+		want  int
+		first string
+		last  string
+	}{
+		{
+			name:  "days across dst start",
+			q:     models.StatsQuery{From: time.Date(2025, 3, 8, 0, 0, 0, 0, ny), To: time.Date(2025, 3, 11, 0, 0, 0, 0, ny), Location: ny, Interval: "day"},
+			want:  3,
+			first: "2025-03-08T00:00:00-05:00",
+			last:  "2025-03-10T00:00:00-04:00",
+		},
+		{
+			name:  "hours across dst start",
+			q:     models.StatsQuery{From: time.Date(2025, 3, 9, 0, 0, 0, 0, ny), To: time.Date(2025, 3, 10, 0, 0, 0, 0, ny), Location: ny, Interval: "hour"},
+			want:  23,
+			first: "2025-03-09T00:00:00-05:00",
+			last:  "2025-03-09T23:00:00-04:00",
+		},
+		{
+			name:  "weeks start on monday",
+			q:     models.StatsQuery{From: time.Date(2025, 3, 5, 12, 0, 0, 0, time.UTC), To: time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC), Location: time.UTC, Interval: "week"},
+			want:  3,
+			first: "2025-03-03T00:00:00Z",
+			last:  "2025-03-17T00:00:00Z",
+		},
+		{
+			name:  "months",
+			q:     models.StatsQuery{From: time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), Location: time.UTC, Interval: "month"},
+			want:  3,
+			first: "2025-01-01T00:00:00Z",
+			last:  "2025-03-01T00:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := statsBuckets(tt.q)
+			if len(got) != tt.want {
+				t.Fatalf("got %d buckets, want %d: %v", len(got), tt.want, got)
+			}
+			if f := got[0].Format(time.RFC3339); f != tt.first {
+				t.Errorf("first = %s, want %s", f, tt.first)
+			}
+			if l := got[len(got)-1].Format(time.RFC3339); l != tt.last {
+				t.Errorf("last = %s, want %s", l, tt.last)
+			}
+		})
+	}
+}
+
+func TestValidateStatsQuery(t *testing.T) {
+	from := mustTime(t, "2025-01-01T00:00:00Z")
+	q := models.StatsQuery{From: from, To: from.AddDate(0, 0, 30), Location: time.UTC, Interval: "day"}
+	if err := ValidateStatsQuery(q); err != nil {
+		t.Fatalf("valid query rejected: %v", err)
+	}
+
+	bad := q
+	bad.Interval = "minute"
+	if ValidateStatsQuery(bad) == nil {
+		t.Error("unknown interval accepted")
+	}
+	bad = q
+	bad.To = bad.From
+	if ValidateStatsQuery(bad) == nil {
+		t.Error("empty window accepted")
+	}
+	bad = q
+	bad.Interval = "hour"
+	bad.To = from.AddDate(0, 6, 0)
+	if ValidateStatsQuery(bad) == nil {
+		t.Error("oversized series accepted")
+	}
+}
+
+func TestWholeHourOffset(t *testing.T) {
+	kolkata, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Skip("no tz database:", err)
+	}
+	now := mustTime(t, "2025-03-01T00:00:00Z")
+	if wholeHourOffset(now, kolkata) {
+		t.Error("Asia/Kolkata reported as whole-hour")
+	}
+	if !wholeHourOffset(now, time.UTC) {
+		t.Error("UTC reported as not whole-hour")
+	}
+}
+
+func TestComparisonWindow(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip("no tz database:", err)
+	}
+
+	tests := []struct {
+		name     string
+		q        models.StatsQuery
+		from, to string
+	}{
+		{
+			name: "previous week across dst start",
+			q:    models.StatsQuery{From: time.Date(2025, 3, 10, 0, 0, 0, 0, ny), To: time.Date(2025, 3, 17, 0, 0, 0, 0, ny), Location: ny},
+			from: "2025-03-03T00:00:00-05:00",
+			to:   "2025-03-10T00:00:00-04:00",
+		},
+		{
+			name: "previous partial hours",
+			q:    models.StatsQuery{From: mustTime(t, "2025-03-01T10:30:00Z"), To: mustTime(t, "2025-03-01T12:30:00Z"), Location: time.UTC},
+			from: "2025-03-01T08:30:00Z",
+			to:   "2025-03-01T10:30:00Z",
+		},
+		{
+			name: "custom start",
+			q: models.StatsQuery{From: time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC), To: time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC), Location: time.UTC,
+				CompareFrom: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)},
+			from: "2024-03-01T00:00:00Z",
+			to:   "2024-04-01T00:00:00Z",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			from, to := comparisonWindow(tt.q)
+			if got := from.Format(time.RFC3339); got != tt.from {
+				t.Errorf("from = %s, want %s", got, tt.from)
+			}
+			if got := to.Format(time.RFC3339); got != tt.to {
+				t.Errorf("to = %s, want %s", got, tt.to)
+			}
+		})
+	}
+}
+
+func TestChange(t *testing.T) {
+	c := change(150, 120)
+	if c.Delta != 30 || c.PctChange == nil || *c.PctChange != 25 {
+		t.Errorf("change(150, 120) = %+v", c)
+	}
+	c = change(1, 3)
+	if c.Delta != -2 || c.PctChange == nil || *c.PctChange != -66.7 {
+		t.Errorf("change(1, 3) = %+v", c)
+	}
+	if c := change(5, 0); c.PctChange != nil {
+		t.Errorf("change(5, 0) pct = %v, want nil", *c.PctChange)
+	}
+}
+
+func TestRawValue(t *testing.T) {
+	values := map[string][]string{
+		"referer": {"", "twitter.com"},
+		"country": {"", "US"},
+		"browser": {"", "Chrome"},
+		"device":  {"", "mobile"},
+		"os":      {"", "iOS"},
+	}
+	for dim, vs := range values {
+		for _, v := range vs {
+			if got := rawValue(displayValue(v)); got != v {
+				t.Errorf("%s: rawValue(displayValue(%q)) = %q", dim, v, got)
+			}
+		}
+	}
+	if got := displayValue(""); got != "direct" {
+		t.Errorf("displayValue(\"\") = %q, want direct", got)
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shortly/internal/models"
+)
+
+var ErrTagExists = errors.New("tag already exists")
+
+type TagService struct {
+	db *pgxpool.Pool
+}
+
+func NewTagService(db *pgxpool.Pool) *TagService {
+	return &TagService{db: db}
+}
+
+// List returns the user's tags with the number of live links under each.
+func (s *TagService) List(ctx context.Context, userID int) ([]models.TagSummary, error) {
+	rows, err := s.db.Query(ctx,
+		`SELECT t.id, t.name, COUNT(l.id)
+		 FROM tags t
+		 LEFT JOIN link_tags lt ON lt.tag_id = t.id
+		 LEFT JOIN links l ON l.id = lt.link_id AND l.deleted_at IS NULL
+		 WHERE t.user_id=$1 GROUP BY t.id ORDER BY t.name`,
+		userID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tags := []models.TagSummary{}
+	for rows.Next() {
+		var t models.TagSummary
+		if err := rows.Scan(&t.ID, &t.Name, &t.LinkCount); err != nil {
+			return nil, err
+		}
+		tags = append(tags, t)
+	}
+	return tags, rows.Err()
+}
+
+func (s *TagService) Create(ctx context.Context, userID int, name string) (*models.Tag, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &models.Tag{}
+	err = s.db.QueryRow(ctx,
+		"INSERT INTO tags (name, user_id) VALUES ($1, $2) RETURNING id, name",
+		name, userID,
+	).Scan(&tag.ID, &tag.Name)
+	if err != nil {
+		if isUniqueViolation(err) {
+			return nil, ErrTagExists
+		}
+		return nil, err
+	}
+	return tag, nil
+}
+
+func (s *TagService) Rename(ctx context.Context, tagID, userID int, name string) (*models.Tag, error) {
+	name, err := normalizeTagName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	tag := &models.Tag{}
+	err = s.db.QueryRow(ctx,
+		"UPDATE tags SET name=$3 WHERE id=$1 AND user_id=$2 RETURNING id, name",
+		tagID, userID, name,
+	).Scan(&tag.ID, &tag.Name)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if isUniqueViolation(err) {
+			return nil, ErrTagExists
+		}
+		return nil, err
+	}
+	return tag, nil
+}
+
+// Merge moves every link tagged with tagID onto intoID and removes tagID.
+func (s *TagService) Merge(ctx context.Context, tagID, intoID, userID int) (*models.TagSummary, error) {
+	if tagID == intoID {
+		return nil, errors.New("cannot merge a tag into itself")
+	}
+
+	tx, err := s.db.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	var owned int
+	tx.QueryRow(ctx, "SELECT COUNT(*) FROM tags WHERE id IN ($1, $2) AND user_id=$3", tagID, intoID, userID).Scan(&owned)
+	if owned != 2 {
+		return nil, ErrNotFound
+	}
+
+	_, err = tx.Exec(ctx,
+		`INSERT INTO link_tags (link_id, tag_id)
+		 SELECT link_id, $2 FROM link_tags WHERE tag_id=$1
+		 ON CONFLICT DO NOTHING`,
+		tagID, intoID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("move links: %w", err)
+	}
+	if _, err := tx.Exec(ctx, "DELETE FROM tags WHERE id=$1", tagID); err != nil {
+		return nil, err
+	}
+
+	merged := &models.TagSummary{}
+	err = tx.QueryRow(ctx,
+		`SELECT t.id, t.name, COUNT(l.id)
+		 FROM tags t
+		 LEFT JOIN link_tags lt ON lt.tag_id = t.id
+		 LEFT JOIN links l ON l.id = lt.link_id AND l.deleted_at IS NULL
+		 WHERE t.id=$1 GROUP BY t.id`,
+		intoID,
+	).Scan(&merged.ID, &merged.Name, &merged.LinkCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+	return merged, nil
+}
+
+func (s *TagService) Delete(ctx context.Context, tagID, userID int) error {
+	res, err := s.db.Exec(ctx, "DELETE FROM tags WHERE id=$1 AND user_id=$2", tagID, userID)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func normalizeTagName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || len(name) > 50 {
+		return "", errors.New("tag name must be 1-50 chars")
+	}
+	return name, nil
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == "23505"
+}
@@ -0,0 +1,138 @@
+package services
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+
+	"github.com/shortly/internal/cache"
+	"github.com/shortly/internal/models"
+)
+
+const (
+	// failures allowed per window before backoff starts; per-code is looser
+	// so one attacker can't trivially lock everybody out of a link
+	unlockFreeAttemptsIP   = 5
+	unlockFreeAttemptsCode = 20
+	unlockFailWindow       = time.Hour
+	unlockBaseLockout      = 2 * time.Second
+	unlockMaxLockout       = 15 * time.Minute
+)
+
+const (
+	EventUnlockFailed = "unlock_failed"
+	EventUnlockLocked = "unlock_locked"
+)
+
+// UnlockGuard throttles password attempts on protected links with per-code
+// and per-IP failure counters in Redis. Past a few free attempts every
+// failure locks further tries out for an exponentially growing period.
+// Without Redis it lets every attempt through.
+type UnlockGuard struct {
+	db    *pgxpool.Pool
+	cache *cache.RedisCache
+}
+
+func NewUnlockGuard(db *pgxpool.Pool, cache *cache.RedisCache) *UnlockGuard {
+	return &UnlockGuard{db: db, cache: cache}
+}
+
+// Check returns how long the caller must wait before trying again, or 0.
+func (g *UnlockGuard) Check(ctx context.Context, code, ip string) time.Duration {
+	if g.cache == nil {
+		return 0
+	}
+	var wait time.Duration
+	for _, key := range []string{"unlock:lock:code:" + code, "unlock:lock:ip:" + ip} {
+		if ttl, err := g.cache.TTL(ctx, key); err == nil && ttl > wait {
+			wait = ttl
+		}
+	}
+	return wait
+}
+
+// Failed counts a wrong password, starts a lockout once past the free
+// attempts and records a security event the link owner can see.
+func (g *UnlockGuard) Failed(ctx context.Context, link *models.Link, ip, userAgent string) {
+	g.recordEvent(ctx, link.ID, EventUnlockFailed, ip, userAgent)
+	if g.cache == nil {
+		return
+	}
+
+	locked := false
+	for _, c := range []struct {
+		scope, id string
+		free      int64
+	}{
+		{"code", link.ShortCode, unlockFreeAttemptsCode},
+		{"ip", ip, unlockFreeAttemptsIP},
+	} {
+		fails, err := g.cache.IncrementTTL(ctx, "unlock:fail:"+c.scope+":"+c.id, unlockFailWindow)
+		if err != nil {
+			log.Println("unlock guard:", err)
+			continue
+		}
+		if fails > c.free {
+			lockout := backoff(fails - c.free)
+			_ = g.cache.Set(ctx, "unlock:lock:"+c.scope+":"+c.id, fails, lockout)
+			locked = true
+		}
+	}
+	if locked {
+		g.recordEvent(ctx, link.ID, EventUnlockLocked, ip, userAgent)
+	}
+}
+
+// Succeeded clears the caller's own failures. The per-code counter is left
+// alone so a valid unlock can't be used to reset an ongoing attack.
+func (g *UnlockGuard) Succeeded(ctx context.Context, ip string) {
+	if g.cache == nil {
+		return
+	}
+	_ = g.cache.DeleteMany(ctx, "unlock:fail:ip:"+ip, "unlock:lock:ip:"+ip)
+}
+
+// Events lists a link's most recent security events, newest first.
+func (g *UnlockGuard) Events(ctx context.Context, linkID, limit int) ([]models.SecurityEvent, error) {
+	rows, err := g.db.Query(ctx,
+		`SELECT id, event_type, COALESCE(ip_address, ''), COALESCE(user_agent, ''), created_at
+		 FROM link_security_events WHERE link_id=$1 ORDER BY created_at DESC, id DESC LIMIT $2`,
+		linkID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []models.SecurityEvent{}
+	for rows.Next() {
+		var e models.SecurityEvent
+		if err := rows.Scan(&e.ID, &e.EventType, &e.IPAddress, &e.UserAgent, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+func (g *UnlockGuard) recordEvent(ctx context.Context, linkID int, eventType, ip, userAgent string) {
+	_, err := g.db.Exec(ctx,
+		"INSERT INTO link_security_events (link_id, event_type, ip_address, user_agent) VALUES ($1, $2, $3, $4)",
+		linkID, eventType, ip, userAgent,
+	)
+	if err != nil {
+		log.Println("record security event:", err)
+	}
+}
+
+// backoff doubles the lockout with every failure past the free attempts.
+func backoff(over int64) time.Duration {
+	d := float64(unlockBaseLockout) * math.Pow(2, float64(over-1))
+	if d > float64(unlockMaxLockout) {
+		return unlockMaxLockout
+	}
+	return time.Duration(d)
+}
@@ -0,0 +1,37 @@
+package utils
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// Cursor marks the boundary row of a keyset-paginated page: the value of the
+// sort key and the row id as tiebreaker.
+type Cursor struct {
+	Key   string `json:"k"`           // sort key the cursor was issued for
+	Value string `json:"v"`           // sort key value of the boundary row
+	ID    int    `json:"id"`          // id of the boundary row
+	Prev  bool   `json:"p,omitempty"` // page backwards from the boundary
+}
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor returns an opaque, URL-safe token for c.
+func EncodeCursor(c Cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// DecodeCursor parses a token produced by EncodeCursor.
+func DecodeCursor(token string) (Cursor, error) {
+	var c Cursor
+	data, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Key == "" {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
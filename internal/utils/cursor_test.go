@@ -0,0 +1,28 @@
+package utils
+
+import "testing"
+
+func TestCursorRoundTrip(t *testing.T) {
+	tests := []Cursor{
+		{Key: "created_at", Value: "2025-03-20T10:00:00.123456Z", ID: 42},
+		{Key: "clicks", Value: "17", ID: 3, Prev: true},
+		{Key: "title", Value: "spring sale / 50% off", ID: 9},
+	}
+	for _, want := range tests {
+		got, err := DecodeCursor(EncodeCursor(want))
+		if err != nil {
+			t.Fatalf("DecodeCursor(EncodeCursor(%+v)): %v", want, err)
+		}
+		if got != want {
+			t.Errorf("round trip = %+v, want %+v", got, want)
+		}
+	}
+}
+
+func TestDecodeCursorInvalid(t *testing.T) {
+	for _, token := range []string{"", "not base64!", "bm90IGpzb24", "e30"} {
+		if _, err := DecodeCursor(token); err != ErrInvalidCursor {
+			t.Errorf("DecodeCursor(%q) error = %v, want ErrInvalidCursor", token, err)
+		}
+	}
+}
@@ -25,7 +25,7 @@ func GenerateShortCode(length int) (string, error) {
 
 // IsValidCustomCode checks if a custom short code is valid.
 func IsValidCustomCode(code string) bool {
-	if len(code) < 3 || len(code) > 20 {
+	if len(code) < 3 || len(code) > 20 || IsReservedCode(code) {
 		return false
 	}
 	for _, c := range code {
@@ -35,3 +35,37 @@ func IsValidCustomCode(code string) bool {
 	}
 	return true
 }
+
+// importedCodeChars are the characters a path segment can carry without
+// percent-encoding, except '%' itself.
+const importedCodeChars = charset + "-._~!$&'()*+,;=:@"
+
+// ReservedCodes are top-level routes that shadow a short code of the same
+// name.
+var ReservedCodes = []string{"api", "health", "qr"}
+
+// IsReservedCode reports whether code, in any case, is one of ReservedCodes.
+func IsReservedCode(code string) bool {
+	for _, r := range ReservedCodes {
+		if strings.EqualFold(code, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidImportedCode checks a short code carried over from another
+// shortener. Those allow short and punctuated keywords such as "go" or
+// "it's", so any unencoded path segment that fits the column and isn't one
+// of our routes is accepted.
+func IsValidImportedCode(code string) bool {
+	if code == "" || len(code) > 20 || code == "." || code == ".." || IsReservedCode(code) {
+		return false
+	}
+	for _, c := range code {
+		if !strings.ContainsRune(importedCodeChars, c) {
+			return false
+		}
+	}
+	return true
+}
@@ -34,6 +34,9 @@ func TestIsValidCustomCode(t *testing.T) {
 		{"", false},
 		{"has space", false},
 		{"abcdefghijklmnopqrstu", false}, // 21 chars
+		{"health", false},
+		{"API", false},
+		{"metrics", true}, // served on its own listener
 	}
 	for _, tt := range tests {
 		if got := IsValidCustomCode(tt.code); got != tt.valid {
@@ -41,3 +44,32 @@ func TestIsValidCustomCode(t *testing.T) {
 		}
 	}
 }
+
+func TestIsValidImportedCode(t *testing.T) {
+	tests := []struct {
+		code  string
+		valid bool
+	}{
+		// keywords from the importer fixtures
+		{"gh", true},
+		{"go", true},
+		{"it's", true},
+		{"x", true},
+		{"a.b~c!", true},
+		{"", false},
+		{".", false},
+		{"..", false},
+		{"has space", false},
+		{"a/b", false},
+		{"50%", false},
+		{"naïve", false},
+		{"health", false},
+		{"API", false},
+		{"abcdefghijklmnopqrstu", false}, // 21 chars
+	}
+	for _, tt := range tests {
+		if got := IsValidImportedCode(tt.code); got != tt.valid {
+			t.Errorf("IsValidImportedCode(%q) = %v, want %v", tt.code, got, tt.valid)
+		}
+	}
+}
@@ -1,52 +1,133 @@
 package utils
 
-import "strings"
+import (
+	"regexp"
+	"strings"
+)
 
-// ParseUserAgent extracts device, browser, and OS from a user agent string.
-func ParseUserAgent(ua string) (device, browser, os string) {
-	ua = strings.ToLower(ua)
+// botPattern matches crawler, link preview, monitor, http library and
+// headless browser user agents, lowercased. Tokens are specific enough not
+// to match in-app browsers that name their app ("Pinterest for Android",
+// "Instagram 312.1") or phones like the Cubot.
+var botPattern = regexp.MustCompile(strings.Join([]string{
+	// generic: "somebot/1.0", "Somebot-Name", a bare "bot" word, a contact
+	// url, which browsers never send
+	`[a-z0-9]bot[/-]`, `\bbot\b`, `\+https?://`,
+	`crawl`, `spider`, `\bslurp\b`, `scraper`, `fetcher`, `archiver`,
+	// link previews
+	`facebookexternalhit`, `facebookcatalog`, `slack-imgproxy`, `slackbot`, `twitterbot`,
+	`linkedinbot`, `\bwhatsapp/`, `telegrambot`, `discordbot`, `skypeuripreview`,
+	`embedly`, `pinterestbot`, `redditbot`, `vkshare`, `iframely`, `bingpreview`,
+	`google web preview`,
+	// monitors and link checkers
+	`uptimerobot`, `pingdom`, `statuscake`, `site24x7`, `newrelicpinger`,
+	`datadog/synthetics`, `uptime-kuma`, `linkcheck`, `link-check`, `validator`,
+	// http libraries and tools
+	`\bcurl/`, `\bwget/`, `\bhttpie/`, `python-requests`, `python-urllib`, `aiohttp/`,
+	`go-http-client`, `okhttp`, `^java/`, `apache-httpclient`, `node-fetch`,
+	`\baxios/`, `libwww-perl`, `^ruby\b`, `\bphp/`, `guzzlehttp`, `postmanruntime`,
+	`\binsomnia/`,
+	// headless browsers and automation
+	`headlesschrome`, `phantomjs`, `puppeteer`, `playwright`, `selenium`,
+	`lighthouse`, `pagespeed`,
+}, "|"))
 
-	// device
-	switch {
-	case strings.Contains(ua, "mobile") || strings.Contains(ua, "android") && !strings.Contains(ua, "tablet"):
-		device = "mobile"
-	case strings.Contains(ua, "tablet") || strings.Contains(ua, "ipad"):
-		device = "tablet"
-	default:
-		device = "desktop"
+// IsBot reports whether a user agent belongs to a crawler, link preview,
+// monitor, script or headless browser. An empty user agent counts as one.
+func IsBot(ua string) bool {
+	ua = strings.ToLower(strings.TrimSpace(ua))
+	return ua == "" || botPattern.MatchString(ua)
+}
+
+// UserAgent is what ParseUA finds in a user agent string. Unknown fields
+// are empty, except Browser and OS which fall back to "Other".
+type UserAgent struct {
+	Device         string // desktop, mobile, tablet or bot
+	DeviceBrand    string
+	DeviceModel    string
+	Browser        string
+	BrowserVersion string
+	OS             string
+	OSVersion      string
+	Engine         string
+}
+
+// ParseUA parses a user agent string with the rules in
+// useragent_rules.yaml. Values are clipped to the clicks table's columns.
+func ParseUA(ua string) UserAgent {
+	var p UserAgent
+
+	if r, m := match(uaRules.browsers, ua); r != nil {
+		p.Browser = r.value("family_replacement", m, 1)
+		p.BrowserVersion = joinVersion(r.value("v1_replacement", m, 2),
+			r.value("v2_replacement", m, 3), r.value("v3_replacement", m, 4))
+	}
+	if r, m := match(uaRules.oses, ua); r != nil {
+		p.OS = r.value("os_replacement", m, 1)
+		p.OSVersion = joinVersion(r.value("os_v1_replacement", m, 2),
+			r.value("os_v2_replacement", m, 3), r.value("os_v3_replacement", m, 4))
+	}
+	if r, m := match(uaRules.devices, ua); r != nil {
+		p.DeviceBrand = r.value("brand_replacement", m, -1)
+		p.DeviceModel = r.value("model_replacement", m, 1)
+		p.Device = r.fields["type"]
+	}
+	if r, m := match(uaRules.engines, ua); r != nil {
+		p.Engine = r.value("engine_replacement", m, 1)
 	}
 
-	// browser
 	switch {
-	case strings.Contains(ua, "firefox"):
-		browser = "Firefox"
-	case strings.Contains(ua, "edg"):
-		browser = "Edge"
-	case strings.Contains(ua, "opr") || strings.Contains(ua, "opera"):
-		browser = "Opera"
-	case strings.Contains(ua, "chrome") || strings.Contains(ua, "chromium"):
-		browser = "Chrome"
-	case strings.Contains(ua, "safari"):
-		browser = "Safari"
+	case IsBot(ua):
+		p.Device = "bot"
+	case p.Device != "":
 	default:
-		browser = "Other"
+		p.Device = deviceType(ua)
 	}
+	if p.Browser == "" {
+		p.Browser = "Other"
+	}
+	if p.OS == "" {
+		p.OS = "Other"
+	}
+
+	p.Browser, p.BrowserVersion = Clip(p.Browser, 50), Clip(p.BrowserVersion, 50)
+	p.OS, p.OSVersion = Clip(p.OS, 50), Clip(p.OSVersion, 50)
+	p.DeviceBrand, p.DeviceModel = Clip(p.DeviceBrand, 50), Clip(p.DeviceModel, 100)
+	p.Engine = Clip(p.Engine, 50)
+	return p
+}
 
-	// os
+// deviceType guesses the form factor of devices no rule names. Android
+// devices without "Mobile" are tablets, as Google asks of browsers.
+func deviceType(ua string) string {
+	ua = strings.ToLower(ua)
 	switch {
-	case strings.Contains(ua, "windows"):
-		os = "Windows"
-	case strings.Contains(ua, "mac os") || strings.Contains(ua, "macintosh"):
-		os = "macOS"
-	case strings.Contains(ua, "linux"):
-		os = "Linux"
+	case strings.Contains(ua, "tablet"):
+		return "tablet"
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "phone"):
+		return "mobile"
 	case strings.Contains(ua, "android"):
-		os = "Android"
-	case strings.Contains(ua, "iphone") || strings.Contains(ua, "ipad"):
-		os = "iOS"
+		return "tablet"
 	default:
-		os = "Other"
+		return "desktop"
+	}
+}
+
+// Clip shortens s to at most n bytes for a column of that width.
+func Clip(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	// don't split a utf-8 sequence
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
 	}
+	return s[:n]
+}
 
-	return
+// ParseUserAgent extracts device, browser, and OS from a user agent string.
+// Bots get the device "bot". See ParseUA for versions, brand and engine.
+func ParseUserAgent(ua string) (device, browser, os string) {
+	p := ParseUA(ua)
+	return p.Device, p.Browser, p.OS
 }
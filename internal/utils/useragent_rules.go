@@ -0,0 +1,179 @@
+package utils
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+//go:embed useragent_rules.yaml
+var uaRulesFile []byte
+
+var uaRules = mustLoadUARules(uaRulesFile)
+
+// uaRule is one entry of a parser section: a regex and its replacements.
+type uaRule struct {
+	re     *regexp.Regexp
+	fields map[string]string
+}
+
+type uaRuleSet struct {
+	browsers, oses, devices, engines []uaRule
+}
+
+func mustLoadUARules(data []byte) *uaRuleSet {
+	rules, err := loadUARules(data)
+	if err != nil {
+		panic("useragent_rules.yaml: " + err.Error())
+	}
+	return rules
+}
+
+// loadUARules reads the subset of YAML that regexes.yaml files use: top-level
+// section keys, each holding a list of flat key/value maps.
+func loadUARules(data []byte) (*uaRuleSet, error) {
+	sections := map[string][]map[string]string{}
+	var section string
+	var entry map[string]string
+
+	for i, line := range strings.Split(string(data), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, " ") {
+			if !strings.HasSuffix(trimmed, ":") {
+				return nil, fmt.Errorf("line %d: expected a section", i+1)
+			}
+			section = strings.TrimSuffix(trimmed, ":")
+			entry = nil
+			continue
+		}
+		if section == "" {
+			return nil, fmt.Errorf("line %d: entry outside a section", i+1)
+		}
+
+		if rest, ok := strings.CutPrefix(trimmed, "- "); ok {
+			entry = map[string]string{}
+			sections[section] = append(sections[section], entry)
+			trimmed = rest
+		} else if entry == nil {
+			return nil, fmt.Errorf("line %d: key outside a list entry", i+1)
+		}
+		key, raw, ok := strings.Cut(trimmed, ":")
+		if !ok {
+			return nil, fmt.Errorf("line %d: expected key: value", i+1)
+		}
+		value, err := yamlScalar(strings.TrimSpace(raw))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", i+1, err)
+		}
+		entry[strings.TrimSpace(key)] = value
+	}
+
+	compile := func(name string) ([]uaRule, error) {
+		var rules []uaRule
+		for n, fields := range sections[name] {
+			expr, ok := fields["regex"]
+			if !ok {
+				return nil, fmt.Errorf("%s[%d]: missing regex", name, n)
+			}
+			if fields["regex_flag"] == "i" {
+				expr = "(?i)" + expr
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("%s[%d]: %w", name, n, err)
+			}
+			rules = append(rules, uaRule{re: re, fields: fields})
+		}
+		return rules, nil
+	}
+
+	var rs uaRuleSet
+	var err error
+	if rs.browsers, err = compile("user_agent_parsers"); err != nil {
+		return nil, err
+	}
+	if rs.oses, err = compile("os_parsers"); err != nil {
+		return nil, err
+	}
+	if rs.devices, err = compile("device_parsers"); err != nil {
+		return nil, err
+	}
+	if rs.engines, err = compile("engine_parsers"); err != nil {
+		return nil, err
+	}
+	return &rs, nil
+}
+
+// yamlScalar decodes a single-quoted or bare YAML scalar.
+func yamlScalar(v string) (string, error) {
+	if !strings.HasPrefix(v, "'") {
+		if strings.HasPrefix(v, `"`) {
+			return "", fmt.Errorf("double-quoted values are not supported")
+		}
+		return v, nil
+	}
+	if len(v) < 2 || !strings.HasSuffix(v, "'") {
+		return "", fmt.Errorf("unterminated quote")
+	}
+	inner := v[1 : len(v)-1]
+	if strings.Count(inner, "'")%2 != 0 {
+		return "", fmt.Errorf("stray quote, write '' for a literal one")
+	}
+	return strings.ReplaceAll(inner, "''", "'"), nil
+}
+
+// match runs rules against ua and returns the first match's submatches.
+func match(rules []uaRule, ua string) (*uaRule, []string) {
+	for i := range rules {
+		if m := rules[i].re.FindStringSubmatch(ua); m != nil {
+			return &rules[i], m
+		}
+	}
+	return nil, nil
+}
+
+// value returns the key's replacement with $n expanded, or capture group
+// def when there is no replacement (def < 0 for no default).
+func (r *uaRule) value(key string, m []string, def int) string {
+	if repl, ok := r.fields[key]; ok {
+		return strings.TrimSpace(expandGroups(repl, m))
+	}
+	if def > 0 && def < len(m) {
+		return m[def]
+	}
+	return ""
+}
+
+func expandGroups(repl string, m []string) string {
+	if !strings.Contains(repl, "$") {
+		return repl
+	}
+	var b strings.Builder
+	for i := 0; i < len(repl); i++ {
+		if repl[i] == '$' && i+1 < len(repl) && repl[i+1] >= '1' && repl[i+1] <= '9' {
+			if n := int(repl[i+1] - '0'); n < len(m) {
+				b.WriteString(m[n])
+			}
+			i++
+			continue
+		}
+		b.WriteByte(repl[i])
+	}
+	return b.String()
+}
+
+// joinVersion joins version parts up to the first missing one.
+func joinVersion(parts ...string) string {
+	var out []string
+	for _, p := range parts {
+		if p == "" {
+			break
+		}
+		out = append(out, p)
+	}
+	return strings.Join(out, ".")
+}
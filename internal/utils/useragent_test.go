@@ -30,3 +30,306 @@ func TestParseUserAgent(t *testing.T) {
 		}
 	}
 }
+
+func TestIsBot(t *testing.T) {
+	tests := []struct {
+		ua  string
+		bot bool
+	}{
+		{"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)", true},
+		{"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)", true},
+		{"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)", true},
+		{"Twitterbot/1.0", true},
+		{"WhatsApp/2.23.20.0", true},
+		{"Mozilla/5.0 (compatible; UptimeRobot/2.0; http://www.uptimerobot.com/)", true},
+		{"curl/8.4.0", true},
+		{"python-requests/2.31.0", true},
+		{"Go-http-client/1.1", true},
+		{"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.0.0 Safari/537.36", true},
+		{"", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", false},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Mobile/15E148 Safari/604.1", false},
+		{"Mozilla/5.0 (Linux; Android 10; CUBOT_X30) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", false},
+		{"Mozilla/5.0 (Linux; Android 11; CUBOT X30 Build/RP1A.200720.011) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36", false},
+		{"Mozilla/5.0 (compatible; Baiduspider/2.0; +http://www.baidu.com/search/spider.html)", true},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/100.0.4896.127 Safari/537.36 Edg/100.0.1185.39 BingPreview/1.0b", true},
+		{"Mozilla/5.0 (compatible; Pinterestbot/1.0; +http://www.pinterest.com/bot.html)", true},
+		{"Ruby", true},
+		// in-app browsers are people
+		{"Pinterest for Android/11.48.0 (SM-S901B; 13)", false},
+		{"Mozilla/5.0 (Linux; Android 13; SM-S901B Build/TP1A.220624.014; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.6099.144 Mobile Safari/537.36 [Pinterest/Android]", false},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [Pinterest/iOS]", false},
+		{"Mozilla/5.0 (Linux; Android 14; Pixel 8 Build/UD1A.230803.041; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.6099.43 Mobile Safari/537.36 Instagram 312.1.0.34.111 Android (34/14; 420dpi; 1080x2400; Google/google; Pixel 8; shiba; shiba; en_US; 548323757)", false},
+		{"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBAV/445.0.0.37.108;FBBV/545133580;FBDV/iPhone15,2;FBMD/iPhone;FBSN/iOS;FBSV/17.1.2;FBSS/3;FBID/phone;FBLC/en_US;FBOP/5]", false},
+	}
+	for _, tt := range tests {
+		if got := IsBot(tt.ua); got != tt.bot {
+			t.Errorf("IsBot(%q) = %v, want %v", tt.ua, got, tt.bot)
+		}
+	}
+
+	if d, _, _ := ParseUserAgent("Twitterbot/1.0"); d != "bot" {
+		t.Errorf("bot device = %q, want bot", d)
+	}
+}
+
+func TestParseUA(t *testing.T) {
+	tests := []struct {
+		ua   string
+		want UserAgent
+	}{
+		// desktop
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.130 Safari/537.36",
+			UserAgent{Device: "desktop", Browser: "Chrome", BrowserVersion: "120.0.6099", OS: "Windows", OSVersion: "10", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91",
+			UserAgent{Device: "desktop", Browser: "Edge", BrowserVersion: "120.0.2210", OS: "Windows", OSVersion: "10", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/70.0.3538.102 Safari/537.36 Edge/18.19582",
+			UserAgent{Device: "desktop", Browser: "Edge", BrowserVersion: "18.19582", OS: "Windows", OSVersion: "10", Engine: "EdgeHTML"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 OPR/106.0.0.0",
+			UserAgent{Device: "desktop", Browser: "Opera", BrowserVersion: "106.0.0", OS: "Windows", OSVersion: "10", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			UserAgent{Device: "desktop", Browser: "Firefox", BrowserVersion: "121.0", OS: "Windows", OSVersion: "10", Engine: "Gecko"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			UserAgent{Device: "desktop", Browser: "IE", BrowserVersion: "11.0", OS: "Windows", OSVersion: "7", Engine: "Trident"},
+		},
+		{
+			"Mozilla/5.0 (compatible; MSIE 9.0; Windows NT 6.1; Trident/5.0)",
+			UserAgent{Device: "desktop", Browser: "IE", BrowserVersion: "9.0", OS: "Windows", OSVersion: "7", Engine: "Trident"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 6.3; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/109.0.0.0 Safari/537.36",
+			UserAgent{Device: "desktop", Browser: "Chrome", BrowserVersion: "109.0.0", OS: "Windows", OSVersion: "8.1", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Safari/605.1.15",
+			UserAgent{Device: "desktop", DeviceBrand: "Apple", DeviceModel: "Mac", Browser: "Safari", BrowserVersion: "17.2", OS: "macOS", OSVersion: "10.15.7", Engine: "WebKit"},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			UserAgent{Device: "desktop", DeviceBrand: "Apple", DeviceModel: "Mac", Browser: "Chrome", BrowserVersion: "120.0.0", OS: "macOS", OSVersion: "10.15.7", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 14.2; rv:121.0) Gecko/20100101 Firefox/121.0",
+			UserAgent{Device: "desktop", DeviceBrand: "Apple", DeviceModel: "Mac", Browser: "Firefox", BrowserVersion: "121.0", OS: "macOS", OSVersion: "14.2", Engine: "Gecko"},
+		},
+		{
+			"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Vivaldi/6.5.3206.48",
+			UserAgent{Device: "desktop", DeviceBrand: "Apple", DeviceModel: "Mac", Browser: "Vivaldi", BrowserVersion: "6.5.3206", OS: "macOS", OSVersion: "10.15.7", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			UserAgent{Device: "desktop", Browser: "Chrome", BrowserVersion: "120.0.0", OS: "Linux", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+			UserAgent{Device: "desktop", Browser: "Firefox", BrowserVersion: "121.0", OS: "Linux", Engine: "Gecko"},
+		},
+		{
+			"Mozilla/5.0 (X11; CrOS x86_64 15633.69.0) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.6045.212 Safari/537.36",
+			UserAgent{Device: "desktop", Browser: "Chrome", BrowserVersion: "119.0.6045", OS: "Chrome OS", OSVersion: "15633.69.0", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/118.0.0.0 YaBrowser/23.11.0.0 Safari/537.36",
+			UserAgent{Device: "desktop", Browser: "Yandex Browser", BrowserVersion: "23.11.0", OS: "Windows", OSVersion: "10", Engine: "Blink"},
+		},
+		{
+			"Opera/9.80 (Windows NT 6.1; WOW64) Presto/2.12.388 Version/12.18",
+			UserAgent{Device: "desktop", Browser: "Opera", BrowserVersion: "12.18", OS: "Windows", OSVersion: "7", Engine: "Presto"},
+		},
+
+		// ios
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.2 Mobile/15E148 Safari/604.1",
+			UserAgent{Device: "mobile", DeviceBrand: "Apple", DeviceModel: "iPhone", Browser: "Safari", BrowserVersion: "17.2", OS: "iOS", OSVersion: "17.2.1", Engine: "WebKit"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) CriOS/120.0.6099.119 Mobile/15E148 Safari/604.1",
+			UserAgent{Device: "mobile", DeviceBrand: "Apple", DeviceModel: "iPhone", Browser: "Chrome", BrowserVersion: "120.0.6099", OS: "iOS", OSVersion: "17.2", Engine: "WebKit"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) FxiOS/121.0 Mobile/15E148 Safari/605.1.15",
+			UserAgent{Device: "mobile", DeviceBrand: "Apple", DeviceModel: "iPhone", Browser: "Firefox", BrowserVersion: "121.0", OS: "iOS", OSVersion: "17.2", Engine: "WebKit"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 EdgiOS/120.2210.126 Mobile/15E148 Safari/605.1.15",
+			UserAgent{Device: "mobile", DeviceBrand: "Apple", DeviceModel: "iPhone", Browser: "Edge", BrowserVersion: "120.2210.126", OS: "iOS", OSVersion: "17.2", Engine: "WebKit"},
+		},
+		{
+			"Mozilla/5.0 (iPad; CPU OS 16_6 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/16.6 Mobile/15E148 Safari/604.1",
+			UserAgent{Device: "tablet", DeviceBrand: "Apple", DeviceModel: "iPad", Browser: "Safari", BrowserVersion: "16.6", OS: "iOS", OSVersion: "16.6", Engine: "WebKit"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 16_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 [FBAN/FBIOS;FBDV/iPhone14,5;FBMD/iPhone;FBSN/iOS;FBSV/16.5;FBSS/3;FBID/phone;FBLC/en_US;FBOP/5;FBAV/420.0.0.32.103]",
+			UserAgent{Device: "mobile", DeviceBrand: "Apple", DeviceModel: "iPhone", Browser: "Facebook", BrowserVersion: "420.0.0", OS: "iOS", OSVersion: "16.5", Engine: "WebKit"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148 Instagram 309.0.0.28.112 (iPhone15,2; iOS 17_1; en_US; en; scale=3.00; 1179x2556; 536988435)",
+			UserAgent{Device: "mobile", DeviceBrand: "Apple", DeviceModel: "iPhone", Browser: "Instagram", BrowserVersion: "309.0.0", OS: "iOS", OSVersion: "17.1", Engine: "WebKit"},
+		},
+		{
+			"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+			UserAgent{Device: "mobile", DeviceBrand: "Apple", DeviceModel: "iPhone", Browser: "Safari", OS: "iOS", OSVersion: "17.0", Engine: "WebKit"},
+		},
+
+		// android
+		{
+			"Mozilla/5.0 (Linux; Android 10; K) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			UserAgent{Device: "mobile", Browser: "Chrome", BrowserVersion: "120.0.0", OS: "Android", OSVersion: "10", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			UserAgent{Device: "mobile", DeviceBrand: "Samsung", DeviceModel: "SM-S918B", Browser: "Chrome", BrowserVersion: "120.0.6099", OS: "Android", OSVersion: "13", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-S918B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/23.0 Chrome/115.0.0.0 Mobile Safari/537.36",
+			UserAgent{Device: "mobile", DeviceBrand: "Samsung", DeviceModel: "SM-S918B", Browser: "Samsung Internet", BrowserVersion: "23.0", OS: "Android", OSVersion: "13", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-X710) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			UserAgent{Device: "tablet", DeviceBrand: "Samsung", DeviceModel: "SM-X710", Browser: "Chrome", BrowserVersion: "120.0.0", OS: "Android", OSVersion: "13", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 8 Pro) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.144 Mobile Safari/537.36",
+			UserAgent{Device: "mobile", DeviceBrand: "Google", DeviceModel: "Pixel 8 Pro", Browser: "Chrome", BrowserVersion: "120.0.6099", OS: "Android", OSVersion: "14", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 14; Pixel 7 Build/UQ1A.231205.015; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/120.0.6099.144 Mobile Safari/537.36",
+			UserAgent{Device: "mobile", DeviceBrand: "Google", DeviceModel: "Pixel 7", Browser: "Chrome WebView", BrowserVersion: "120.0.6099", OS: "Android", OSVersion: "14", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Android 14; Mobile; rv:121.0) Gecko/121.0 Firefox/121.0",
+			UserAgent{Device: "mobile", Browser: "Firefox", BrowserVersion: "121.0", OS: "Android", OSVersion: "14", Engine: "Gecko"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 12; Redmi Note 11 Build/SKQ1.211103.001) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.6045.193 Mobile Safari/537.36",
+			UserAgent{Device: "mobile", DeviceBrand: "Xiaomi", DeviceModel: "Redmi Note 11", Browser: "Chrome", BrowserVersion: "119.0.6045", OS: "Android", OSVersion: "12", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 10; HUAWEI P30 Pro Build/HUAWEIVOG-L29) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/114.0.0.0 Mobile Safari/537.36",
+			UserAgent{Device: "mobile", DeviceBrand: "Huawei", DeviceModel: "P30 Pro", Browser: "Chrome", BrowserVersion: "114.0.0", OS: "Android", OSVersion: "10", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; moto g power (2022)) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36",
+			UserAgent{Device: "mobile", DeviceBrand: "Motorola", DeviceModel: "moto g power (2022)", Browser: "Chrome", BrowserVersion: "120.0.0", OS: "Android", OSVersion: "13", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; CPH2423) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36 OPR/79.2.4195.76461",
+			UserAgent{Device: "mobile", DeviceBrand: "OPPO", DeviceModel: "CPH2423", Browser: "Opera", BrowserVersion: "79.2.4195", OS: "Android", OSVersion: "13", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 13; SM-A536B) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Mobile Safari/537.36 EdgA/120.0.2210.115",
+			UserAgent{Device: "mobile", DeviceBrand: "Samsung", DeviceModel: "SM-A536B", Browser: "Edge", BrowserVersion: "120.0.2210", OS: "Android", OSVersion: "13", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; U; Android 4.4.2; en-us; GT-I9505 Build/KOT49H) AppleWebKit/534.30 (KHTML, like Gecko) Version/4.0 Mobile Safari/534.30",
+			UserAgent{Device: "mobile", DeviceBrand: "Samsung", DeviceModel: "GT-I9505", Browser: "Android Browser", BrowserVersion: "4.0", OS: "Android", OSVersion: "4.4.2", Engine: "WebKit"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 11; KFTRWI) AppleWebKit/537.36 (KHTML, like Gecko) Silk/120.3.1 like Chrome/120.0.6099.230 Safari/537.36",
+			UserAgent{Device: "tablet", DeviceBrand: "Amazon", DeviceModel: "Kindle", Browser: "Chrome", BrowserVersion: "120.0.6099", OS: "Android", OSVersion: "11", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 12; Lenovo TB-X606F) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			UserAgent{Device: "tablet", DeviceModel: "Lenovo TB-X606F", Browser: "Chrome", BrowserVersion: "120.0.0", OS: "Android", OSVersion: "12", Engine: "Blink"},
+		},
+		{
+			"Mozilla/5.0 (Mobile; LYF/F300B/LYF-F300B-001-01-15-130718-i;Android; rv:48.0) Gecko/48.0 Firefox/48.0 KAIOS/2.5",
+			UserAgent{Device: "mobile", Browser: "Firefox", BrowserVersion: "48.0", OS: "Android", Engine: "Gecko"},
+		},
+
+		// bots and tools
+		{
+			"Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			UserAgent{Device: "bot", Browser: "Googlebot", BrowserVersion: "2.1", OS: "Other"},
+		},
+		{
+			"Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.129 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			UserAgent{Device: "bot", DeviceBrand: "Google", DeviceModel: "Nexus 5", Browser: "Googlebot", BrowserVersion: "2.1", OS: "Android", OSVersion: "6.0.1", Engine: "Blink"},
+		},
+		{
+			"Slackbot-LinkExpanding 1.0 (+https://api.slack.com/robots)",
+			UserAgent{Device: "bot", Browser: "Slackbot", OS: "Other"},
+		},
+		{
+			"facebookexternalhit/1.1 (+http://www.facebook.com/externalhit_uatext.php)",
+			UserAgent{Device: "bot", Browser: "facebookexternalhit", BrowserVersion: "1.1", OS: "Other"},
+		},
+		{
+			"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko) HeadlessChrome/120.0.6099.109 Safari/537.36",
+			UserAgent{Device: "bot", Browser: "HeadlessChrome", BrowserVersion: "120.0.6099", OS: "Linux", Engine: "Blink"},
+		},
+		{
+			"curl/8.4.0",
+			UserAgent{Device: "bot", Browser: "curl", BrowserVersion: "8.4.0", OS: "Other"},
+		},
+		{
+			"python-requests/2.31.0",
+			UserAgent{Device: "bot", Browser: "Python Requests", BrowserVersion: "2.31.0", OS: "Other"},
+		},
+		{
+			"",
+			UserAgent{Device: "bot", Browser: "Other", OS: "Other"},
+		},
+		{
+			"SomethingNobodyHasSeen/1.0",
+			UserAgent{Device: "desktop", Browser: "Other", OS: "Other"},
+		},
+	}
+	for _, tt := range tests {
+		if got := ParseUA(tt.ua); got != tt.want {
+			t.Errorf("ParseUA(%q)\n got  %+v\n want %+v", tt.ua, got, tt.want)
+		}
+	}
+}
+
+func TestLoadUARules(t *testing.T) {
+	rules, err := loadUARules([]byte(`
+# comment
+user_agent_parsers:
+  - regex: '(Foo)/(\d+)'
+    family_replacement: 'Foo''s $1'
+os_parsers:
+  - regex: 'bar'
+    os_replacement: Bar
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rules.browsers) != 1 || len(rules.oses) != 1 {
+		t.Fatalf("rules = %+v", rules)
+	}
+	r, m := match(rules.browsers, "Foo/3")
+	if r == nil {
+		t.Fatal("no match")
+	}
+	if got := r.value("family_replacement", m, 1); got != "Foo's Foo" {
+		t.Errorf("family = %q", got)
+	}
+	if got := r.value("v1_replacement", m, 2); got != "3" {
+		t.Errorf("v1 = %q", got)
+	}
+
+	bad := []string{
+		"user_agent_parsers:\n  - family_replacement: 'x'\n",
+		"user_agent_parsers:\n  - regex: '(?<=x)'\n",
+		"user_agent_parsers:\n  - regex: \"x\"\n",
+		"user_agent_parsers:\n  - regex: 'x\n",
+		"  - regex: 'x'\n",
+	}
+	for _, data := range bad {
+		if _, err := loadUARules([]byte(data)); err == nil {
+			t.Errorf("loadUARules(%q) succeeded", data)
+		}
+	}
+}